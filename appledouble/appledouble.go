@@ -0,0 +1,261 @@
+// Package appledouble encodes extended attributes in AppleDouble files,
+// the convention macOS uses to carry attributes through archive formats
+// that have no room for them.
+//
+// For a member "dir/name" the attributes travel in a separate member
+// "dir/._name" placed before it: bsdtar and bsdcpio on macOS write such
+// sidecars into tar and cpio archives, and ditto and Archive Utility write
+// them into zip archives below a "__MACOSX/" directory. The sidecar holds
+// an AppleDouble header whose Finder Info entry is extended with an
+// "ATTR" block listing the attributes, as written by copyfile(3).
+// com.apple.FinderInfo and com.apple.ResourceFork are kept in the Finder
+// Info and resource fork entries proper, as macOS expects.
+package appledouble
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ivaxer/go-xattr"
+)
+
+// Attributes with dedicated AppleDouble entries.
+const (
+	FinderInfoAttr   = "com.apple.FinderInfo"
+	ResourceForkAttr = "com.apple.ResourceFork"
+)
+
+// SidecarPrefix is the prefix of the base name of sidecar members.
+const SidecarPrefix = "._"
+
+const (
+	magic          = 0x00051607
+	version        = 0x00020000
+	attrMagic      = 0x41545452 // "ATTR"
+	entryFinder    = 9
+	entryResource  = 2
+	headerSize     = 26
+	entryDescSize  = 12
+	finderInfoSize = 32
+	attrHeaderSize = 36
+	attrEntrySize  = 11 // without the name
+
+	// finderOffset is where the Finder Info entry starts in files with
+	// two entries.
+	finderOffset = headerSize + 2*entryDescSize
+	// attrOffset is where the ATTR header starts, after the Finder Info
+	// and two bytes of padding.
+	attrOffset = finderOffset + finderInfoSize + 2
+)
+
+var filler = []byte("Mac OS X        ")
+
+// ErrMalformed is returned when an AppleDouble file can't be decoded.
+var ErrMalformed = errors.New("appledouble: malformed AppleDouble file")
+
+// SidecarName returns the name of the sidecar member carrying the
+// attributes of the member name.
+func SidecarName(name string) string {
+	dir, base := path.Split(strings.TrimSuffix(name, "/"))
+	return dir + SidecarPrefix + base
+}
+
+// TargetName returns the name of the member whose attributes the member
+// name carries. It reports false if name is not a sidecar. The "__MACOSX/"
+// directory used in zip archives is stripped.
+func TargetName(name string) (string, bool) {
+	name = strings.TrimPrefix(name, "__MACOSX/")
+	dir, base := path.Split(name)
+	if !strings.HasPrefix(base, SidecarPrefix) || len(base) == len(SidecarPrefix) {
+		return "", false
+	}
+	return dir + base[len(SidecarPrefix):], true
+}
+
+// Encode returns an AppleDouble file carrying attrs.
+func Encode(attrs map[string][]byte) ([]byte, error) {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		if name == FinderInfoAttr || name == ResourceForkAttr {
+			continue
+		}
+		if len(name) == 0 || len(name) > 127 {
+			return nil, fmt.Errorf("appledouble: invalid attribute name %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	finder := attrs[FinderInfoAttr]
+	if finder != nil && len(finder) != finderInfoSize {
+		return nil, fmt.Errorf("appledouble: invalid %s length %d", FinderInfoAttr, len(finder))
+	}
+
+	// Lay out the entry table, then the values.
+	entriesEnd := attrOffset + attrHeaderSize
+	for _, name := range names {
+		entriesEnd += entryLength(name)
+	}
+	dataStart := entriesEnd
+	dataEnd := dataStart
+	for _, name := range names {
+		dataEnd += len(attrs[name])
+	}
+
+	b := make([]byte, dataEnd, dataEnd+len(attrs[ResourceForkAttr]))
+	be := binary.BigEndian
+	be.PutUint32(b[0:], magic)
+	be.PutUint32(b[4:], version)
+	copy(b[8:], filler)
+	be.PutUint16(b[24:], 2)
+	be.PutUint32(b[headerSize:], entryFinder)
+	be.PutUint32(b[headerSize+4:], finderOffset)
+	be.PutUint32(b[headerSize+8:], uint32(dataEnd-finderOffset))
+	be.PutUint32(b[headerSize+12:], entryResource)
+	be.PutUint32(b[headerSize+16:], uint32(dataEnd))
+	be.PutUint32(b[headerSize+20:], uint32(len(attrs[ResourceForkAttr])))
+	copy(b[finderOffset:], finder)
+
+	h := b[attrOffset:]
+	be.PutUint32(h[0:], attrMagic)
+	be.PutUint32(h[8:], uint32(dataEnd))
+	be.PutUint32(h[12:], uint32(dataStart))
+	be.PutUint32(h[16:], uint32(dataEnd-dataStart))
+	be.PutUint16(h[34:], uint16(len(names)))
+
+	off, data := attrOffset+attrHeaderSize, dataStart
+	for _, name := range names {
+		value := attrs[name]
+		be.PutUint32(b[off:], uint32(data))
+		be.PutUint32(b[off+4:], uint32(len(value)))
+		b[off+10] = byte(len(name) + 1)
+		copy(b[off+attrEntrySize:], name)
+		off += entryLength(name)
+		data += copy(b[data:], value)
+	}
+
+	return append(b, attrs[ResourceForkAttr]...), nil
+}
+
+// entryLength returns the aligned length of the ATTR entry for name,
+// which is stored NUL terminated.
+func entryLength(name string) int {
+	return (attrEntrySize + len(name) + 1 + 3) &^ 3
+}
+
+// Decode returns the attributes carried by an AppleDouble file. Finder
+// Info that is all zeros and empty resource forks are omitted.
+func Decode(b []byte) (map[string][]byte, error) {
+	be := binary.BigEndian
+	if len(b) < headerSize || be.Uint32(b) != magic {
+		return nil, ErrMalformed
+	}
+
+	attrs := make(map[string][]byte)
+	n := int(be.Uint16(b[24:]))
+	if len(b) < headerSize+n*entryDescSize {
+		return nil, ErrMalformed
+	}
+	for i := 0; i < n; i++ {
+		e := b[headerSize+i*entryDescSize:]
+		id, off, length := be.Uint32(e), int64(be.Uint32(e[4:])), int64(be.Uint32(e[8:]))
+		if off+length > int64(len(b)) {
+			return nil, ErrMalformed
+		}
+		entry := b[off : off+length]
+
+		switch id {
+		case entryResource:
+			if length > 0 {
+				attrs[ResourceForkAttr] = append([]byte{}, entry...)
+			}
+		case entryFinder:
+			if length < finderInfoSize {
+				return nil, ErrMalformed
+			}
+			if !isZero(entry[:finderInfoSize]) {
+				attrs[FinderInfoAttr] = append([]byte{}, entry[:finderInfoSize]...)
+			}
+			if err := decodeAttrs(b, entry[finderInfoSize:], attrs); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return attrs, nil
+}
+
+// decodeAttrs decodes the ATTR block following the Finder Info, if there
+// is one. Value offsets are relative to the start of the file.
+func decodeAttrs(file, b []byte, attrs map[string][]byte) error {
+	be := binary.BigEndian
+	if len(b) < 2+attrHeaderSize || be.Uint32(b[2:]) != attrMagic {
+		return nil
+	}
+	b = b[2:]
+
+	n := int(be.Uint16(b[34:]))
+	p := b[attrHeaderSize:]
+	for i := 0; i < n; i++ {
+		if len(p) < attrEntrySize {
+			return ErrMalformed
+		}
+		off, length := int64(be.Uint32(p)), int64(be.Uint32(p[4:]))
+		nameLen := int(p[10])
+		if nameLen == 0 || len(p) < attrEntrySize+nameLen || off+length > int64(len(file)) {
+			return ErrMalformed
+		}
+		name := strings.TrimRight(string(p[attrEntrySize:attrEntrySize+nameLen]), "\x00")
+		attrs[name] = append([]byte{}, file[off:off+length]...)
+
+		l := (attrEntrySize + nameLen + 3) &^ 3
+		if l > len(p) {
+			l = len(p)
+		}
+		p = p[l:]
+	}
+	return nil
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Collect returns the extended attributes of path.
+func Collect(path string) (map[string][]byte, error) {
+	names, err := xattr.List(path)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := make(map[string][]byte, len(names))
+	for _, name := range names {
+		value, err := xattr.Get(path, name)
+		if err != nil {
+			if xattr.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		attrs[name] = value
+	}
+	return attrs, nil
+}
+
+// Restore sets attrs as extended attributes of path.
+func Restore(path string, attrs map[string][]byte) error {
+	for name, value := range attrs {
+		if err := xattr.Set(path, name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
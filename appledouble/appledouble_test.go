@@ -0,0 +1,94 @@
+package appledouble
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	finder := make([]byte, 32)
+	copy(finder, "TEXTttxt")
+
+	attrs := map[string][]byte{
+		"user.mime_type":                       []byte("text/plain"),
+		"com.apple.metadata:kMDItemWhereFroms": {0x62, 0x70, 0x6c, 0, 1, 2},
+		"security.selinux":                     []byte("system_u:object_r:etc_t:s0\x00"),
+		"user.empty":                           {},
+		FinderInfoAttr:                         finder,
+		ResourceForkAttr:                       []byte("resource fork data"),
+	}
+
+	b, err := Encode(attrs)
+	if err != nil {
+		t.Fatalf("Encode() failed: %v", err)
+	}
+	if binary.BigEndian.Uint32(b[attrOffset:]) != attrMagic {
+		t.Errorf("Encode(): ATTR header not at offset %d", attrOffset)
+	}
+
+	got, err := Decode(b)
+	if err != nil {
+		t.Fatalf("Decode() failed: %v", err)
+	}
+	if len(got) != len(attrs) {
+		t.Errorf("Decode(): got %d attributes, expected %d", len(got), len(attrs))
+	}
+	for name, value := range attrs {
+		if !bytes.Equal(got[name], value) {
+			t.Errorf("Decode(): %s: got %q, expected %q", name, got[name], value)
+		}
+	}
+
+	for _, n := range []int{10, attrOffset + 40, len(b) - 20} {
+		if _, err := Decode(b[:n]); err == nil {
+			t.Errorf("Decode(b[:%d]): expected error", n)
+		}
+	}
+}
+
+func TestEncodeEmpty(t *testing.T) {
+	b, err := Encode(nil)
+	if err != nil {
+		t.Fatalf("Encode() failed: %v", err)
+	}
+	got, err := Decode(b)
+	if err != nil || len(got) != 0 {
+		t.Errorf("Decode(): got %v, %v", got, err)
+	}
+
+	if _, err := Encode(map[string][]byte{FinderInfoAttr: {1}}); err == nil {
+		t.Error("Encode(): expected error on short FinderInfo")
+	}
+}
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		name, sidecar string
+	}{
+		{"file", "._file"},
+		{"dir/file.txt", "dir/._file.txt"},
+		{"dir/sub/", "dir/._sub"},
+	}
+	for _, test := range tests {
+		if got := SidecarName(test.name); got != test.sidecar {
+			t.Errorf("SidecarName(%q): got %q, expected %q", test.name, got, test.sidecar)
+		}
+	}
+
+	targets := []struct {
+		name, target string
+		ok           bool
+	}{
+		{"dir/._file", "dir/file", true},
+		{"__MACOSX/dir/._file", "dir/file", true},
+		{"dir/file", "", false},
+		{"dir/._", "", false},
+	}
+	for _, test := range targets {
+		got, ok := TargetName(test.name)
+		if got != test.target || ok != test.ok {
+			t.Errorf("TargetName(%q): got %q %v, expected %q %v", test.name, got, ok, test.target, test.ok)
+		}
+	}
+}
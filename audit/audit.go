@@ -0,0 +1,266 @@
+// Package audit scans directory trees for extended attributes that grant
+// privileges or may hide persistence: file capabilities, trusted.*
+// attributes, SELinux labels that differ from the expected ones, ACLs
+// granting write access to everyone, and unusually large values.
+//
+// Findings are plain structs that encode to JSON:
+//
+//	{"path":"/usr/bin/ping","kind":"capability","severity":"high",
+//	 "attr":"security.capability","detail":"cap_net_raw=ep"}
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ivaxer/go-xattr"
+	"github.com/ivaxer/go-xattr/internal/posixacl"
+	"github.com/ivaxer/go-xattr/internal/vfscap"
+)
+
+// SELinuxAttr is the attribute holding SELinux labels.
+const SELinuxAttr = "security.selinux"
+
+// DefaultMaxValueSize is the value size above which values are reported
+// when Config.MaxValueSize is zero.
+const DefaultMaxValueSize = 4096
+
+// Kind classifies findings.
+type Kind string
+
+// Kinds of findings.
+const (
+	Capability    Kind = "capability"
+	Trusted       Kind = "trusted"
+	SELinux       Kind = "selinux"
+	WorldWritable Kind = "acl-world-writable"
+	LargeValue    Kind = "large-value"
+	Malformed     Kind = "malformed"
+	Unreadable    Kind = "unreadable"
+)
+
+// Severities.
+const (
+	High   = "high"
+	Medium = "medium"
+	Low    = "low"
+)
+
+// Finding is a suspicious attribute, or a file that couldn't be checked.
+type Finding struct {
+	Path     string `json:"path"`
+	Kind     Kind   `json:"kind"`
+	Severity string `json:"severity"`
+	Attr     string `json:"attr,omitempty"`
+	Detail   string `json:"detail"`
+}
+
+// LabelRule gives the expected SELinux label of the paths matching
+// Pattern, a regular expression matched against the whole path relative to
+// the scanned root, with a leading slash, as in file_contexts.
+type LabelRule struct {
+	Pattern string `json:"pattern"`
+	Label   string `json:"label"`
+}
+
+// Config configures a scan. The zero value reports every capability,
+// every trusted.* attribute, world writable ACLs and values above
+// DefaultMaxValueSize.
+type Config struct {
+	// AllowedTrusted lists the expected trusted.* attributes. Entries
+	// ending in '.' match a prefix, e.g. "trusted.overlay.".
+	AllowedTrusted []string `json:"allowed_trusted"`
+
+	// AllowedCapabilities lists the paths, relative to the root with a
+	// leading slash, allowed to carry file capabilities.
+	AllowedCapabilities []string `json:"allowed_capabilities"`
+
+	// Labels holds the expected SELinux labels. When several rules
+	// match, the last one wins. Files matching no rule are not checked.
+	Labels []LabelRule `json:"labels"`
+
+	// MaxValueSize is the largest value size not reported. Negative
+	// disables the check.
+	MaxValueSize int `json:"max_value_size"`
+}
+
+// LoadConfig reads a Config in JSON.
+func LoadConfig(r io.Reader) (*Config, error) {
+	cfg := new(Config)
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("audit: invalid config: %v", err)
+	}
+	return cfg, nil
+}
+
+type label struct {
+	re    *regexp.Regexp
+	label string
+}
+
+type scanner struct {
+	root   string
+	cfg    Config
+	labels []label
+	caps   map[string]bool
+	fn     func(Finding) error
+}
+
+// Scan walks the tree at root, without following symbolic links, and calls
+// fn with each finding. It stops at the first error returned by fn and
+// returns it. Files that can't be read are reported as Unreadable
+// findings.
+func Scan(root string, cfg *Config, fn func(Finding) error) error {
+	s := &scanner{root: root, fn: fn, caps: make(map[string]bool)}
+	if cfg != nil {
+		s.cfg = *cfg
+	}
+	if s.cfg.MaxValueSize == 0 {
+		s.cfg.MaxValueSize = DefaultMaxValueSize
+	}
+	for _, r := range s.cfg.Labels {
+		re, err := regexp.Compile("^(?:" + r.Pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("audit: invalid label pattern %q: %v", r.Pattern, err)
+		}
+		s.labels = append(s.labels, label{re, r.Label})
+	}
+	for _, p := range s.cfg.AllowedCapabilities {
+		s.caps[p] = true
+	}
+
+	return filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return s.report(path, Unreadable, Low, "", err.Error())
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+		return s.check(path)
+	})
+}
+
+func (s *scanner) report(path string, kind Kind, severity, attr, detail string) error {
+	return s.fn(Finding{Path: path, Kind: kind, Severity: severity, Attr: attr, Detail: detail})
+}
+
+// rel returns path relative to the root, with a leading slash.
+func (s *scanner) rel(path string) string {
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil || rel == "." {
+		return "/"
+	}
+	return "/" + filepath.ToSlash(rel)
+}
+
+func (s *scanner) check(path string) error {
+	names, err := xattr.List(path)
+	if err != nil {
+		return s.report(path, Unreadable, Low, "", err.Error())
+	}
+
+	hasLabel := false
+	for _, name := range names {
+		value, err := xattr.Get(path, name)
+		if err != nil {
+			if xattr.IsNotExist(err) {
+				continue
+			}
+			if err := s.report(path, Unreadable, Low, name, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if s.cfg.MaxValueSize >= 0 && len(value) > s.cfg.MaxValueSize {
+			detail := fmt.Sprintf("%d bytes", len(value))
+			if err := s.report(path, LargeValue, Medium, name, detail); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case name == vfscap.Attr:
+			err = s.checkCapability(path, value)
+		case strings.HasPrefix(name, "trusted."):
+			if !allowed(s.cfg.AllowedTrusted, name) {
+				err = s.report(path, Trusted, Medium, name, fmt.Sprintf("%d bytes", len(value)))
+			}
+		case name == posixacl.AccessAttr, name == posixacl.DefaultAttr:
+			err = s.checkACL(path, name, value)
+		case name == SELinuxAttr:
+			hasLabel = true
+			err = s.checkLabel(path, string(bytes.TrimRight(value, "\x00")))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if !hasLabel {
+		if expected, ok := s.expectedLabel(path); ok {
+			return s.report(path, SELinux, Medium, SELinuxAttr, "unlabeled, expected "+expected)
+		}
+	}
+	return nil
+}
+
+func allowed(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if p == name || strings.HasSuffix(p, ".") && strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *scanner) checkCapability(path string, value []byte) error {
+	c, err := vfscap.Parse(value)
+	if err != nil {
+		return s.report(path, Malformed, Medium, vfscap.Attr, err.Error())
+	}
+	if c.Permitted == 0 && c.Inheritable == 0 || s.caps[s.rel(path)] {
+		return nil
+	}
+	return s.report(path, Capability, High, vfscap.Attr, c.String())
+}
+
+func (s *scanner) checkACL(path, name string, value []byte) error {
+	acl, err := posixacl.Parse(value)
+	if err != nil {
+		return s.report(path, Malformed, Medium, name, err.Error())
+	}
+	for _, e := range acl {
+		if e.Tag == posixacl.Other && e.Perm&posixacl.Write != 0 {
+			return s.report(path, WorldWritable, High, name, acl.String())
+		}
+	}
+	return nil
+}
+
+func (s *scanner) expectedLabel(path string) (string, bool) {
+	rel := s.rel(path)
+	expected, ok := "", false
+	for _, l := range s.labels {
+		if l.re.MatchString(rel) {
+			expected, ok = l.label, true
+		}
+	}
+	return expected, ok
+}
+
+func (s *scanner) checkLabel(path, got string) error {
+	expected, ok := s.expectedLabel(path)
+	if !ok || got == expected {
+		return nil
+	}
+	return s.report(path, SELinux, Medium, SELinuxAttr, fmt.Sprintf("label %s, expected %s", got, expected))
+}
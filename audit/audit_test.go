@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ivaxer/go-xattr"
+	"github.com/ivaxer/go-xattr/internal/posixacl"
+	"github.com/ivaxer/go-xattr/internal/vfscap"
+	"github.com/ivaxer/go-xattr/xattrtest"
+)
+
+func TestScan(t *testing.T) {
+	dir := xattrtest.TempDir(t)
+
+	var expected []string
+	// set sets an attribute and records the finding it should cause.
+	// Attributes outside the user namespace need privileges or kernel
+	// support the test may not have, so failures only drop the check.
+	set := func(name, attr string, value []byte, kind Kind) {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			ioutil.WriteFile(path, nil, 0644)
+		}
+		if err := xattr.Set(path, attr, value); err != nil {
+			if strings.HasPrefix(attr, "user.") {
+				t.Fatalf("Set(%s) failed: %v", attr, err)
+			}
+			t.Logf("Set(%s) failed, not checking: %v", attr, err)
+			return
+		}
+		if kind != "" {
+			expected = append(expected, "/"+name+" "+string(kind))
+		}
+	}
+
+	caps, _ := (&vfscap.Capability{Revision: vfscap.Revision2, Effective: true, Permitted: 1 << 13}).Marshal()
+	set("ping", vfscap.Attr, caps, Capability)
+	set("allowed", vfscap.Attr, caps, "")
+	set("overlay", "trusted.overlay.opaque", []byte("y"), "")
+	set("hidden", "trusted.payload", []byte("x"), Trusted)
+	// Minimal ACLs are folded into the mode, so name a user.
+	acl := posixacl.ACL{
+		{Tag: posixacl.UserObj, Perm: 6, ID: posixacl.UndefinedID},
+		{Tag: posixacl.User, Perm: 4, ID: 1000},
+		{Tag: posixacl.GroupObj, Perm: 4, ID: posixacl.UndefinedID},
+		{Tag: posixacl.Mask, Perm: 6, ID: posixacl.UndefinedID},
+		{Tag: posixacl.Other, Perm: 6, ID: posixacl.UndefinedID},
+	}
+	set("shared", posixacl.AccessAttr, acl.Marshal(), WorldWritable)
+	set("big", "user.blob", make([]byte, 2048), LargeValue)
+	set("etc.conf", SELinuxAttr, []byte("system_u:object_r:etc_t:s0\x00"), "")
+	set("etc.bad", SELinuxAttr, []byte("system_u:object_r:user_tmp_t:s0\x00"), SELinux)
+	set("broken", vfscap.Attr, []byte{1, 2, 3}, Malformed)
+
+	cfg, err := LoadConfig(strings.NewReader(`{
+		"allowed_trusted": ["trusted.overlay."],
+		"allowed_capabilities": ["/allowed"],
+		"labels": [
+			{"pattern": "/etc\\..*", "label": "system_u:object_r:etc_t:s0"}
+		],
+		"max_value_size": 1024
+	}`))
+	if err != nil {
+		t.Fatalf("LoadConfig() failed: %v", err)
+	}
+
+	var got []string
+	err = Scan(dir, cfg, func(f Finding) error {
+		rel, _ := filepath.Rel(dir, f.Path)
+		got = append(got, "/"+rel+" "+string(f.Kind))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Scan() failed: %v", err)
+	}
+
+	sort.Strings(got)
+	sort.Strings(expected)
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Scan(): got\n%s\nexpected\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+
+	if _, err := LoadConfig(strings.NewReader(`{"unknown": 1}`)); err == nil {
+		t.Error("LoadConfig(): expected error on unknown field")
+	}
+	if err := Scan(dir, &Config{Labels: []LabelRule{{Pattern: "("}}}, nil); err == nil {
+		t.Error("Scan(): expected error on invalid pattern")
+	}
+}
@@ -0,0 +1,168 @@
+// Package btrfs reads and sets Btrfs properties that are exposed through
+// extended attributes in the btrfs namespace.
+//
+// Currently this is the compression property, which is what
+// "btrfs property set <path> compression <value>" stores in
+// btrfs.compression.
+package btrfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ivaxer/go-xattr"
+)
+
+// CompressionAttr is the attribute holding the compression property.
+const CompressionAttr = "btrfs.compression"
+
+// Compression algorithms.
+const (
+	None = "none"
+	Zlib = "zlib"
+	LZO  = "lzo"
+	Zstd = "zstd"
+)
+
+// ErrNotBtrfs is returned when a path is not on a Btrfs filesystem.
+var ErrNotBtrfs = errors.New("btrfs: not a btrfs filesystem")
+
+// Compression is the value of the compression property. A zero Level
+// selects the default level of the algorithm. The zero Compression means
+// the property is unset and the mount options decide.
+type Compression struct {
+	Algorithm string
+	Level     int
+}
+
+// ParseCompression parses a compression property value such as "zstd" or
+// "zlib:3".
+func ParseCompression(s string) (Compression, error) {
+	var c Compression
+	c.Algorithm = s
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		level, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return Compression{}, fmt.Errorf("btrfs: invalid compression %q", s)
+		}
+		c.Algorithm, c.Level = s[:i], level
+	}
+	if c.Algorithm == "no" {
+		c.Algorithm = None
+	}
+
+	if err := c.Validate(); err != nil {
+		return Compression{}, err
+	}
+	return c, nil
+}
+
+// Validate checks that the algorithm is known and the level is in its
+// range.
+func (c Compression) Validate() error {
+	var max int
+	switch c.Algorithm {
+	case "", None, LZO:
+	case Zlib:
+		max = 9
+	case Zstd:
+		max = 15
+	default:
+		return fmt.Errorf("btrfs: unknown compression algorithm %q", c.Algorithm)
+	}
+
+	if c.Level < 0 || c.Level > max {
+		if max == 0 {
+			return fmt.Errorf("btrfs: compression %q doesn't take a level", c.Algorithm)
+		}
+		return fmt.Errorf("btrfs: %s compression level %d out of range 1-%d", c.Algorithm, c.Level, max)
+	}
+	return nil
+}
+
+// String formats the compression in the form stored in the property.
+func (c Compression) String() string {
+	if c.Level == 0 {
+		return c.Algorithm
+	}
+	return c.Algorithm + ":" + strconv.Itoa(c.Level)
+}
+
+// GetCompression retrieves the compression property of path. The zero
+// Compression is returned if the property is unset.
+func GetCompression(path string) (Compression, error) {
+	if err := checkBtrfs(path); err != nil {
+		return Compression{}, err
+	}
+
+	b, err := xattr.Get(path, CompressionAttr)
+	if err != nil {
+		if xattr.IsNotExist(err) {
+			return Compression{}, nil
+		}
+		return Compression{}, err
+	}
+	return ParseCompression(string(b))
+}
+
+// SetCompression sets the compression property of path. On directories it
+// applies to files created afterwards. Setting the zero Compression
+// removes the property.
+func SetCompression(path string, c Compression) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if err := checkBtrfs(path); err != nil {
+		return err
+	}
+	return setCompression(path, c)
+}
+
+func setCompression(path string, c Compression) error {
+	if c.Algorithm == "" {
+		err := xattr.Remove(path, CompressionAttr)
+		if xattr.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return xattr.Set(path, CompressionAttr, []byte(c.String()))
+}
+
+// SetCompressionTree sets the compression property of root and of every
+// regular file and directory below it. Subtrees on other filesystems are
+// rejected with ErrNotBtrfs. Existing file data is not recompressed.
+func SetCompressionTree(root string, c Compression) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	return filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() && !fi.IsDir() {
+			return nil
+		}
+		if fi.IsDir() {
+			if err := checkBtrfs(path); err != nil {
+				return err
+			}
+		}
+		return setCompression(path, c)
+	})
+}
+
+func checkBtrfs(path string) error {
+	ok, err := isBtrfs(path)
+	if err != nil {
+		return &os.PathError{Op: "statfs", Path: path, Err: err}
+	}
+	if !ok {
+		return &os.PathError{Op: "statfs", Path: path, Err: ErrNotBtrfs}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package btrfs
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestParseCompression(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected Compression
+	}{
+		{"zlib", Compression{Zlib, 0}},
+		{"zlib:9", Compression{Zlib, 9}},
+		{"zstd:3", Compression{Zstd, 3}},
+		{"lzo", Compression{LZO, 0}},
+		{"no", Compression{None, 0}},
+		{"none", Compression{None, 0}},
+	}
+
+	for _, test := range tests {
+		got, err := ParseCompression(test.value)
+		if err != nil {
+			t.Errorf("ParseCompression(%q) failed: %v", test.value, err)
+			continue
+		}
+		if got != test.expected {
+			t.Errorf("ParseCompression(%q): got %+v, expected %+v", test.value, got, test.expected)
+		}
+	}
+
+	for _, s := range []string{"gzip", "zlib:10", "zstd:x", "lzo:1", "zstd:-1"} {
+		if _, err := ParseCompression(s); err == nil {
+			t.Errorf("ParseCompression(%q): expected error", s)
+		}
+	}
+}
+
+func TestNotBtrfs(t *testing.T) {
+	f, err := ioutil.TempFile("", "test_btrfs_")
+	if err != nil {
+		t.Fatalf("TempFile() failed: %v", err)
+	}
+	defer func() { f.Close(); os.Remove(f.Name()) }()
+
+	if ok, _ := isBtrfs(f.Name()); ok {
+		t.Skip("temporary directory is on btrfs")
+	}
+
+	_, err = GetCompression(f.Name())
+	if e, ok := err.(*os.PathError); !ok || e.Err != ErrNotBtrfs {
+		t.Errorf("GetCompression(): unexpected error value: %v", err)
+	}
+	err = SetCompression(f.Name(), Compression{Algorithm: Zstd})
+	if e, ok := err.(*os.PathError); !ok || e.Err != ErrNotBtrfs {
+		t.Errorf("SetCompression(): unexpected error value: %v", err)
+	}
+}
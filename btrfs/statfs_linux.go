@@ -0,0 +1,15 @@
+package btrfs
+
+import (
+	"syscall"
+)
+
+const superMagic = 0x9123683e
+
+func isBtrfs(path string) (bool, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return false, err
+	}
+	return uint32(st.Type) == superMagic, nil
+}
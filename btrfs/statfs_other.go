@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package btrfs
+
+func isBtrfs(path string) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,269 @@
+// Package ceph parses and formats the virtual extended attributes CephFS
+// exposes for file layouts, recursive directory statistics and quotas.
+//
+// The values are plain text, e.g.
+//
+//	ceph.file.layout="stripe_unit=4194304 stripe_count=1 object_size=4194304 pool=cephfs_data"
+//	ceph.quota="max_bytes=100000000 max_files=10000"
+//	ceph.dir.rctime="1636461005.314990210"
+//
+// so the parsers in this package work on values obtained anywhere, and the
+// Get/Set helpers just wrap xattr.Get and xattr.Set.
+package ceph
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ivaxer/go-xattr"
+)
+
+// Attribute names.
+const (
+	FileLayoutAttr = "ceph.file.layout"
+	DirLayoutAttr  = "ceph.dir.layout"
+	QuotaAttr      = "ceph.quota"
+	MaxBytesAttr   = "ceph.quota.max_bytes"
+	MaxFilesAttr   = "ceph.quota.max_files"
+	EntriesAttr    = "ceph.dir.entries"
+	FilesAttr      = "ceph.dir.files"
+	SubdirsAttr    = "ceph.dir.subdirs"
+	RBytesAttr     = "ceph.dir.rbytes"
+	REntriesAttr   = "ceph.dir.rentries"
+	RFilesAttr     = "ceph.dir.rfiles"
+	RSubdirsAttr   = "ceph.dir.rsubdirs"
+	RCtimeAttr     = "ceph.dir.rctime"
+)
+
+// Layout describes how file data is striped over RADOS objects. Zero
+// fields are unset and are omitted by String.
+type Layout struct {
+	StripeUnit    int64
+	StripeCount   int64
+	ObjectSize    int64
+	Pool          string
+	PoolNamespace string
+}
+
+// ParseLayout parses the value of ceph.file.layout or ceph.dir.layout.
+func ParseLayout(s string) (Layout, error) {
+	var l Layout
+	err := parseFields(s, func(key, val string) (err error) {
+		switch key {
+		case "stripe_unit":
+			l.StripeUnit, err = strconv.ParseInt(val, 10, 64)
+		case "stripe_count":
+			l.StripeCount, err = strconv.ParseInt(val, 10, 64)
+		case "object_size":
+			l.ObjectSize, err = strconv.ParseInt(val, 10, 64)
+		case "pool":
+			l.Pool = val
+		case "pool_namespace":
+			l.PoolNamespace = val
+		default:
+			err = fmt.Errorf("unknown layout field %q", key)
+		}
+		return
+	})
+	if err != nil {
+		return Layout{}, fmt.Errorf("ceph: invalid layout %q: %v", s, err)
+	}
+	return l, nil
+}
+
+// String formats the layout in the form accepted by ceph.dir.layout and
+// ceph.file.layout.
+func (l Layout) String() string {
+	var fields []string
+	if l.StripeUnit != 0 {
+		fields = append(fields, "stripe_unit="+strconv.FormatInt(l.StripeUnit, 10))
+	}
+	if l.StripeCount != 0 {
+		fields = append(fields, "stripe_count="+strconv.FormatInt(l.StripeCount, 10))
+	}
+	if l.ObjectSize != 0 {
+		fields = append(fields, "object_size="+strconv.FormatInt(l.ObjectSize, 10))
+	}
+	if l.Pool != "" {
+		fields = append(fields, "pool="+l.Pool)
+	}
+	if l.PoolNamespace != "" {
+		fields = append(fields, "pool_namespace="+l.PoolNamespace)
+	}
+	return strings.Join(fields, " ")
+}
+
+// Quota holds directory quota limits. Zero means no limit.
+type Quota struct {
+	MaxBytes int64
+	MaxFiles int64
+}
+
+// ParseQuota parses the value of ceph.quota.
+func ParseQuota(s string) (Quota, error) {
+	var q Quota
+	err := parseFields(s, func(key, val string) (err error) {
+		switch key {
+		case "max_bytes":
+			q.MaxBytes, err = strconv.ParseInt(val, 10, 64)
+		case "max_files":
+			q.MaxFiles, err = strconv.ParseInt(val, 10, 64)
+		default:
+			err = fmt.Errorf("unknown quota field %q", key)
+		}
+		return
+	})
+	if err != nil {
+		return Quota{}, fmt.Errorf("ceph: invalid quota %q: %v", s, err)
+	}
+	return q, nil
+}
+
+// String formats the quota in the form accepted by ceph.quota.
+func (q Quota) String() string {
+	return fmt.Sprintf("max_bytes=%d max_files=%d", q.MaxBytes, q.MaxFiles)
+}
+
+// DirStats holds the directory statistics from the ceph.dir.* attributes.
+// The R-prefixed fields are recursive over the whole subtree.
+type DirStats struct {
+	Entries  int64
+	Files    int64
+	Subdirs  int64
+	RBytes   int64
+	REntries int64
+	RFiles   int64
+	RSubdirs int64
+	RCtime   time.Time
+}
+
+// ParseRCtime parses the value of ceph.dir.rctime, which is a Unix time
+// with a seconds and a nanoseconds part separated by a dot.
+func ParseRCtime(s string) (time.Time, error) {
+	sec, nsec := s, "0"
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		sec, nsec = s[:i], s[i+1:]
+	}
+
+	secs, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ceph: invalid rctime %q", s)
+	}
+	nsecs, err := strconv.ParseInt(nsec, 10, 64)
+	if err != nil || nsecs >= 1e9 {
+		return time.Time{}, fmt.Errorf("ceph: invalid rctime %q", s)
+	}
+	return time.Unix(secs, nsecs), nil
+}
+
+// FormatRCtime formats t the way ceph.dir.rctime is reported.
+func FormatRCtime(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+// parseFields splits a space separated list of key=value pairs.
+func parseFields(s string, f func(key, val string) error) error {
+	for _, field := range strings.Fields(s) {
+		i := strings.IndexByte(field, '=')
+		if i < 0 {
+			return fmt.Errorf("missing '=' in %q", field)
+		}
+		if err := f(field[:i], field[i+1:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetLayout retrieves the layout of path. For directories without an
+// explicitly set layout the error satisfies xattr.IsNotExist.
+func GetLayout(path string) (Layout, error) {
+	attr, err := layoutAttr(path)
+	if err != nil {
+		return Layout{}, err
+	}
+
+	b, err := xattr.Get(path, attr)
+	if err != nil {
+		return Layout{}, err
+	}
+	return ParseLayout(string(b))
+}
+
+// SetLayout sets the layout of path. File layouts can only be changed
+// while the file is empty; directory layouts apply to files created in it
+// afterwards.
+func SetLayout(path string, l Layout) error {
+	attr, err := layoutAttr(path)
+	if err != nil {
+		return err
+	}
+	return xattr.Set(path, attr, []byte(l.String()))
+}
+
+func layoutAttr(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if fi.IsDir() {
+		return DirLayoutAttr, nil
+	}
+	return FileLayoutAttr, nil
+}
+
+// GetQuota retrieves the quota of directory path.
+func GetQuota(path string) (Quota, error) {
+	b, err := xattr.Get(path, QuotaAttr)
+	if err != nil {
+		return Quota{}, err
+	}
+	return ParseQuota(string(b))
+}
+
+// SetQuota sets the quota of directory path. Zero fields remove the
+// corresponding limit.
+func SetQuota(path string, q Quota) error {
+	if err := xattr.Set(path, MaxBytesAttr, []byte(strconv.FormatInt(q.MaxBytes, 10))); err != nil {
+		return err
+	}
+	return xattr.Set(path, MaxFilesAttr, []byte(strconv.FormatInt(q.MaxFiles, 10)))
+}
+
+// GetDirStats retrieves the statistics of directory path.
+func GetDirStats(path string) (*DirStats, error) {
+	st := new(DirStats)
+	ints := []struct {
+		attr string
+		dest *int64
+	}{
+		{EntriesAttr, &st.Entries},
+		{FilesAttr, &st.Files},
+		{SubdirsAttr, &st.Subdirs},
+		{RBytesAttr, &st.RBytes},
+		{REntriesAttr, &st.REntries},
+		{RFilesAttr, &st.RFiles},
+		{RSubdirsAttr, &st.RSubdirs},
+	}
+	for _, i := range ints {
+		b, err := xattr.Get(path, i.attr)
+		if err != nil {
+			return nil, err
+		}
+		if *i.dest, err = strconv.ParseInt(string(b), 10, 64); err != nil {
+			return nil, fmt.Errorf("ceph: invalid %s %q", i.attr, b)
+		}
+	}
+
+	b, err := xattr.Get(path, RCtimeAttr)
+	if err != nil {
+		return nil, err
+	}
+	if st.RCtime, err = ParseRCtime(string(b)); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
@@ -0,0 +1,82 @@
+package ceph
+
+import (
+	"testing"
+	"time"
+)
+
+var layoutFixtures = []struct {
+	value    string
+	expected Layout
+}{
+	{
+		"stripe_unit=4194304 stripe_count=1 object_size=4194304 pool=cephfs_data",
+		Layout{StripeUnit: 4194304, StripeCount: 1, ObjectSize: 4194304, Pool: "cephfs_data"},
+	},
+	{
+		"stripe_unit=1048576 stripe_count=8 object_size=8388608 pool=fs_ec pool_namespace=tenant1",
+		Layout{StripeUnit: 1048576, StripeCount: 8, ObjectSize: 8388608, Pool: "fs_ec", PoolNamespace: "tenant1"},
+	},
+	{
+		"pool=cephfs_ssd",
+		Layout{Pool: "cephfs_ssd"},
+	},
+}
+
+func TestParseLayout(t *testing.T) {
+	for _, f := range layoutFixtures {
+		got, err := ParseLayout(f.value)
+		if err != nil {
+			t.Errorf("ParseLayout(%q) failed: %v", f.value, err)
+			continue
+		}
+		if got != f.expected {
+			t.Errorf("ParseLayout(%q): got %+v, expected %+v", f.value, got, f.expected)
+		}
+		if s := got.String(); s != f.value {
+			t.Errorf("Layout.String(): got %q, expected %q", s, f.value)
+		}
+	}
+
+	for _, s := range []string{"stripe_unit", "stripe_unit=abc", "color=blue"} {
+		if _, err := ParseLayout(s); err == nil {
+			t.Errorf("ParseLayout(%q): expected error", s)
+		}
+	}
+}
+
+func TestParseQuota(t *testing.T) {
+	q, err := ParseQuota("max_bytes=100000000 max_files=10000")
+	if err != nil {
+		t.Fatalf("ParseQuota() failed: %v", err)
+	}
+	if expected := (Quota{MaxBytes: 100000000, MaxFiles: 10000}); q != expected {
+		t.Errorf("ParseQuota(): got %+v, expected %+v", q, expected)
+	}
+	if s := q.String(); s != "max_bytes=100000000 max_files=10000" {
+		t.Errorf("Quota.String(): got %q", s)
+	}
+
+	if _, err := ParseQuota("max_bytes=-"); err == nil {
+		t.Error("ParseQuota(): expected error")
+	}
+}
+
+func TestParseRCtime(t *testing.T) {
+	got, err := ParseRCtime("1636461005.314990210")
+	if err != nil {
+		t.Fatalf("ParseRCtime() failed: %v", err)
+	}
+	if expected := time.Unix(1636461005, 314990210); !got.Equal(expected) {
+		t.Errorf("ParseRCtime(): got %v, expected %v", got, expected)
+	}
+	if s := FormatRCtime(got); s != "1636461005.314990210" {
+		t.Errorf("FormatRCtime(): got %q", s)
+	}
+
+	for _, s := range []string{"", "abc", "1.x", "1.1000000000"} {
+		if _, err := ParseRCtime(s); err == nil {
+			t.Errorf("ParseRCtime(%q): expected error", s)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/ivaxer/go-xattr/audit"
+)
+
+func runAudit(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	config := fs.String("config", "", "JSON `file` with the audit configuration")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: xattr audit [-config FILE] DIR")
+	}
+
+	var cfg *audit.Config
+	if *config != "" {
+		f, err := os.Open(*config)
+		if err != nil {
+			return err
+		}
+		cfg, err = audit.LoadConfig(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	return audit.Scan(fs.Arg(0), cfg, func(f audit.Finding) error {
+		return enc.Encode(f)
+	})
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/ivaxer/go-xattr"
+)
+
+func runEdit(args []string) error {
+	fs := flag.NewFlagSet("edit", flag.ExitOnError)
+	dryRun := fs.Bool("n", false, "print the changes without applying them")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: xattr edit [-n] FILE")
+	}
+	path := fs.Arg(0)
+
+	current, err := load(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile("", "xattr-edit-*.txt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	err = dump(tmp, path, current)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	var edited map[string][]byte
+	for {
+		if err := runEditor(tmp.Name()); err != nil {
+			return err
+		}
+		b, err := ioutil.ReadFile(tmp.Name())
+		if err != nil {
+			return err
+		}
+		if edited, err = parse(bytes.NewReader(b)); err == nil {
+			break
+		}
+		fmt.Fprintf(os.Stderr, "xattr edit: %v\n", err)
+		if !confirm("Edit again? [Y/n] ") {
+			return errors.New("no changes applied")
+		}
+	}
+
+	changes := diff(current, edited)
+	for _, c := range changes {
+		fmt.Println(c)
+		if *dryRun {
+			continue
+		}
+		if err := c.apply(path); err != nil {
+			return err
+		}
+	}
+	if len(changes) == 0 {
+		fmt.Println("no changes")
+	}
+	return nil
+}
+
+// load returns the attributes of path.
+func load(path string) (map[string][]byte, error) {
+	names, err := xattr.List(path)
+	if err != nil {
+		return nil, err
+	}
+	attrs := make(map[string][]byte, len(names))
+	for _, name := range names {
+		v, err := xattr.Get(path, name)
+		if err != nil {
+			if xattr.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		attrs[name] = v
+	}
+	return attrs, nil
+}
+
+// runEditor opens the editor from $VISUAL or $EDITOR on path. The variable
+// may hold arguments, as in "code --wait".
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running %s: %v", editor, err)
+	}
+	return nil
+}
+
+func confirm(prompt string) bool {
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "" || answer == "y" || answer == "yes"
+}
+
+// change is an attribute to set, or to remove if value is nil.
+type change struct {
+	name  string
+	value []byte
+}
+
+func (c change) String() string {
+	if c.value == nil {
+		return "remove " + c.name
+	}
+	return "set " + c.name + "=" + encodeValue(c.value)
+}
+
+func (c change) apply(path string) error {
+	if c.value == nil {
+		return xattr.Remove(path, c.name)
+	}
+	return xattr.Set(path, c.name, c.value)
+}
+
+// diff returns the changes turning from into to, removals first.
+func diff(from, to map[string][]byte) []change {
+	var removed, set []change
+	for name := range from {
+		if _, ok := to[name]; !ok {
+			removed = append(removed, change{name: name})
+		}
+	}
+	for name, v := range to {
+		if old, ok := from[name]; !ok || !bytes.Equal(old, v) {
+			set = append(set, change{name, v})
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i].name < removed[j].name })
+	sort.Slice(set, func(i, j int) bool { return set[i].name < set[j].name })
+	return append(removed, set...)
+}
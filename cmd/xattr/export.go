@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ivaxer/go-xattr/export"
+)
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	tsv := fs.Bool("tsv", false, "write tab separated values instead of CSV")
+	encoding := fs.String("encoding", "text", "value encoding: text, hex, base64, sha256 or none")
+	max := fs.Int("max", 0, "truncate encoded values longer than `n` bytes, 0 for no limit")
+	aggregate := fs.Bool("aggregate", false, "count occurrences per attribute name instead")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: xattr export [-tsv] [-encoding ENC] [-max N] [-aggregate] DIR")
+	}
+
+	enc, err := export.ParseEncoding(*encoding)
+	if err != nil {
+		return err
+	}
+	skipped := 0
+	opts := &export.Options{TSV: *tsv, Encoding: enc, MaxValueLen: *max}
+	opts.Skipped = func(path string, err error) {
+		skipped++
+		fmt.Fprintf(os.Stderr, "xattr export: skipping %s: %v\n", path, err)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	if *aggregate {
+		err = export.WriteAggregate(w, fs.Arg(0), opts)
+	} else {
+		err = export.Write(w, fs.Arg(0), opts)
+	}
+	if ferr := w.Flush(); ferr != nil {
+		return ferr
+	}
+	if skipped > 0 {
+		// Each was reported above.
+		return fmt.Errorf("%d files skipped", skipped)
+	}
+	return err
+}
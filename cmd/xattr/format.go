@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// dump writes attrs in the format of getfattr -d, which setfattr --restore
+// reads back.
+func dump(w io.Writer, path string, attrs map[string][]byte) error {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# file: %s\n", escape(path))
+	for _, name := range names {
+		fmt.Fprintf(bw, "%s=%s\n", escape(name), encodeValue(attrs[name]))
+	}
+	return bw.Flush()
+}
+
+// encodeValue encodes a value the way getfattr does: printable text is
+// quoted and everything else is base64. A trailing NUL, common in labels,
+// is kept in the quoted form as \000.
+func encodeValue(v []byte) string {
+	if isText(bytes.TrimSuffix(v, []byte{0})) {
+		return `"` + escape(string(v)) + `"`
+	}
+	return "0s" + base64.StdEncoding.EncodeToString(v)
+}
+
+func isText(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if r < ' ' && r != '\t' && r != '\n' || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// escape writes control characters, backslashes, quotes and '=' as octal
+// escapes, as getfattr does.
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < ' ' || c == 0x7f || c == '\\' || c == '"' || c == '=' {
+			fmt.Fprintf(&b, "\\%03o", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func unescape(s string) (string, error) {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+4 > len(s) {
+			return "", fmt.Errorf("truncated escape in %q", s)
+		}
+		n, err := strconv.ParseUint(s[i+1:i+4], 8, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape in %q", s)
+		}
+		b.WriteByte(byte(n))
+		i += 3
+	}
+	return b.String(), nil
+}
+
+// parse reads attributes in the format written by dump. Blank lines and
+// comments are ignored. Names must be unique and carry a namespace.
+func parse(r io.Reader) (map[string][]byte, error) {
+	attrs := make(map[string][]byte)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+
+		name, value := text, ""
+		if i := strings.IndexByte(text, '='); i >= 0 {
+			name, value = text[:i], text[i+1:]
+		}
+		name, err := unescape(name)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if i := strings.IndexByte(name, '.'); i <= 0 || i == len(name)-1 {
+			return nil, fmt.Errorf("line %d: invalid attribute name %q", line, name)
+		}
+		if _, ok := attrs[name]; ok {
+			return nil, fmt.Errorf("line %d: duplicate attribute %s", line, name)
+		}
+		v, err := decodeValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %v", line, name, err)
+		}
+		attrs[name] = v
+	}
+	return attrs, sc.Err()
+}
+
+func decodeValue(s string) ([]byte, error) {
+	switch {
+	case s == "":
+		return []byte{}, nil
+	case strings.HasPrefix(s, `"`):
+		if len(s) < 2 || !strings.HasSuffix(s, `"`) {
+			return nil, fmt.Errorf("unterminated quoted value")
+		}
+		v, err := unescape(s[1 : len(s)-1])
+		return []byte(v), err
+	case strings.HasPrefix(s, "0x"), strings.HasPrefix(s, "0X"):
+		return hex.DecodeString(s[2:])
+	case strings.HasPrefix(s, "0s"), strings.HasPrefix(s, "0S"):
+		return base64.StdEncoding.DecodeString(s[2:])
+	}
+	return nil, fmt.Errorf("value must be quoted or start with 0x or 0s")
+}
@@ -0,0 +1,70 @@
+// Command xattr works with extended attributes of files.
+//
+// Usage:
+//
+//	xattr edit [-n] FILE
+//	xattr audit [-config FILE] DIR
+//	xattr export [-tsv] [-encoding ENC] [-max N] [-aggregate] DIR
+//
+// edit writes the attributes of FILE to a temporary file in the text
+// format of getfattr -d, opens it in $VISUAL or $EDITOR, and applies the
+// changes made with Set and Remove once the editor exits. The edited text
+// is validated first; if it doesn't parse, the editor can be reopened. With
+// -n the changes are printed but not applied. Values are written quoted,
+// with octal escapes, when they are printable text and as 0s-prefixed
+// base64 otherwise; 0x-prefixed hex is accepted as well.
+//
+// audit scans the tree at DIR for attributes granting privileges or
+// looking out of place, see package audit, and prints the findings as JSON
+// objects, one per line. The configuration file holds an audit.Config in
+// JSON.
+//
+// export writes the attributes of the tree at DIR as CSV, or TSV with
+// -tsv, one row per attribute, see package export. -encoding selects how
+// values are written and -max truncates them; -aggregate writes one row
+// per attribute name with the number of files carrying it instead.
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+type command struct {
+	run   func(args []string) error
+	usage string
+}
+
+var commands = map[string]command{
+	"audit":  {runAudit, "audit [-config FILE] DIR"},
+	"edit":   {runEdit, "edit [-n] FILE"},
+	"export": {runExport, "export [-tsv] [-encoding ENC] [-max N] [-aggregate] DIR"},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "\txattr %s\n", commands[name].usage)
+	}
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "xattr %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
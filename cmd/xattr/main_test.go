@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ivaxer/go-xattr"
+	"github.com/ivaxer/go-xattr/xattrtest"
+)
+
+var formatAttrs = map[string][]byte{
+	"user.mime_type":   []byte("text/plain"),
+	"user.quote":       []byte("a \"b\" = c\\d\n"),
+	"security.selinux": []byte("system_u:object_r:etc_t:s0\x00"),
+	"user.binary":      {0, 1, 2, 0xff},
+	"user.empty":       {},
+	"user.utf8":        []byte("naïve"),
+}
+
+func TestDumpParse(t *testing.T) {
+	var buf bytes.Buffer
+	if err := dump(&buf, "/tmp/a=b", formatAttrs); err != nil {
+		t.Fatalf("dump() failed: %v", err)
+	}
+	expected := `# file: /tmp/a\075b
+security.selinux="system_u:object_r:etc_t:s0\000"
+user.binary=0sAAEC/w==
+user.empty=""
+user.mime_type="text/plain"
+user.quote="a \042b\042 \075 c\134d\012"
+user.utf8="naïve"
+`
+	if buf.String() != expected {
+		t.Errorf("dump(): got\n%s\nexpected\n%s", buf.String(), expected)
+	}
+
+	got, err := parse(&buf)
+	if err != nil {
+		t.Fatalf("parse() failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, formatAttrs) {
+		t.Errorf("parse(): got %q, expected %q", got, formatAttrs)
+	}
+
+	got, err = parse(strings.NewReader("\n# comment\nuser.hex=0x00ff\nuser.bare\n"))
+	if err != nil {
+		t.Fatalf("parse() failed: %v", err)
+	}
+	if !bytes.Equal(got["user.hex"], []byte{0, 0xff}) || got["user.bare"] == nil {
+		t.Errorf("parse(): got %q", got)
+	}
+
+	for _, text := range []string{
+		"noprefix=\"x\"",
+		"user.=\"x\"",
+		"user.a=\"x\"\nuser.a=\"y\"",
+		"user.a=\"unterminated",
+		"user.a=plain",
+		"user.a=0xzz",
+		"user.a=\"\\12\"",
+	} {
+		if _, err := parse(strings.NewReader(text)); err == nil {
+			t.Errorf("parse(%q): expected error", text)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	from := map[string][]byte{"user.a": []byte("1"), "user.b": []byte("2"), "user.c": []byte("3")}
+	to := map[string][]byte{"user.a": []byte("1"), "user.b": []byte("changed"), "user.d": {}}
+
+	var got []string
+	for _, c := range diff(from, to) {
+		got = append(got, c.String())
+	}
+	expected := []string{"remove user.c", `set user.b="changed"`, `set user.d=""`}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("diff(): got %q, expected %q", got, expected)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	path := xattrtest.TempFile(t)
+	xattr.Set(path, "user.keep", []byte("1"))
+	xattr.Set(path, "user.drop", []byte("2"))
+
+	sed, err := exec.LookPath("sed")
+	if err != nil {
+		t.Skip("sed not found")
+	}
+	// The editor deletes user.drop and adds user.new.
+	script := filepath.Join(filepath.Dir(path), filepath.Base(path)+".sh")
+	ioutil.WriteFile(script, []byte("#!/bin/sh\n"+sed+" -i -e '/^user.drop/d' -e '$a user.new=0s3q2+7w==' \"$1\"\n"), 0755)
+	defer os.Remove(script)
+	os.Setenv("VISUAL", script)
+	defer os.Unsetenv("VISUAL")
+
+	stdout := os.Stdout
+	os.Stdout, _ = os.Open(os.DevNull)
+	err = runEdit([]string{path})
+	os.Stdout.Close()
+	os.Stdout = stdout
+	if err != nil {
+		t.Fatalf("runEdit() failed: %v", err)
+	}
+
+	got, err := load(path)
+	if err != nil {
+		t.Fatalf("load() failed: %v", err)
+	}
+	expected := map[string][]byte{"user.keep": []byte("1"), "user.new": {0xde, 0xad, 0xbe, 0xef}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("runEdit(): got %q, expected %q", got, expected)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// ssize_t fgetxattr(int fd, const char *name, void *value, size_t size, u_int32_t position, int options);
+func fgetxattr(fd int, name string, buf []byte) (sz int, err error) {
+	n, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return
+	}
+
+	var b *byte
+	if len(buf) > 0 {
+		b = &buf[0]
+	}
+
+	r0, _, e1 := syscall.Syscall6(syscall.SYS_FGETXATTR,
+		uintptr(fd),
+		uintptr(unsafe.Pointer(n)),
+		uintptr(unsafe.Pointer(b)),
+		uintptr(len(buf)), 0, 0)
+
+	sz = int(r0)
+	if e1 != 0 {
+		err = e1
+	}
+	return
+}
+
+// ssize_t flistxattr(int fd, char *namebuf, size_t size, int options);
+func flistxattr(fd int, buf []byte) (sz int, err error) {
+	var b *byte
+	if len(buf) > 0 {
+		b = &buf[0]
+	}
+
+	r0, _, e1 := syscall.Syscall6(syscall.SYS_FLISTXATTR,
+		uintptr(fd),
+		uintptr(unsafe.Pointer(b)),
+		uintptr(len(buf)), 0, 0, 0)
+
+	sz = int(r0)
+	if e1 != 0 {
+		err = e1
+	}
+	return
+}
+
+// int fsetxattr(int fd, const char *name, void *value, size_t size, u_int32_t position, int options);
+func fsetxattr(fd int, name string, data []byte) (err error) {
+	n, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return
+	}
+
+	var b *byte
+	if len(data) > 0 {
+		b = &data[0]
+	}
+
+	_, _, e1 := syscall.Syscall6(syscall.SYS_FSETXATTR,
+		uintptr(fd),
+		uintptr(unsafe.Pointer(n)),
+		uintptr(unsafe.Pointer(b)),
+		uintptr(len(data)), 0, 0)
+
+	if e1 != 0 {
+		err = e1
+	}
+	return
+}
+
+// int fremovexattr(int fd, const char *name, int options);
+func fremovexattr(fd int, name string) (err error) {
+	n, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return
+	}
+
+	_, _, e1 := syscall.Syscall(syscall.SYS_FREMOVEXATTR,
+		uintptr(fd),
+		uintptr(unsafe.Pointer(n)), 0)
+
+	if e1 != 0 {
+		err = e1
+	}
+	return
+}
@@ -0,0 +1,55 @@
+// Command xattrd serves extended attributes of files below a root
+// directory over HTTP with JSON endpoints, for tools that can't use this
+// package directly.
+//
+// Usage:
+//
+//	xattrd [-addr 127.0.0.1:8080] [-allow user.] [-readonly] -root DIR
+//
+// Endpoints, with paths relative to the root:
+//
+//	GET  /v1/list?path=P            {"path": P, "names": [...]}
+//	GET  /v1/get?path=P&name=N      {"path": P, "name": N, "value": base64}
+//	GET  /v1/dump?path=P            {"path": P, "attrs": {N: base64, ...}}
+//	POST /v1/set    {"path": P, "name": N, "value": base64}
+//	POST /v1/remove {"path": P, "name": N}
+//
+// Errors are reported as {"error": message} with a matching status code.
+// Only attributes in the namespaces given by -allow are visible and
+// writable. Entries ending in "." are namespaces, such as "user." or
+// "user.app.", other entries allow the attribute of that name only.
+// Paths resolving outside the root, including through symbolic links, are
+// rejected. Error messages give paths relative to the root. The server
+// has no authentication and listens on the loopback interface unless
+// -addr says otherwise.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8080", "listen address")
+	root := flag.String("root", "", "directory to serve")
+	allow := flag.String("allow", "user.", "comma separated list of allowed namespaces and names, empty for all")
+	readOnly := flag.Bool("readonly", false, "reject set and remove requests")
+	flag.Parse()
+
+	if *root == "" {
+		log.Fatal("xattrd: -root is required")
+	}
+
+	var namespaces []string
+	if *allow != "" {
+		namespaces = strings.Split(*allow, ",")
+	}
+
+	s, err := newServer(*root, namespaces, *readOnly)
+	if err != nil {
+		log.Fatalf("xattrd: %v", err)
+	}
+	log.Fatal(http.ListenAndServe(*addr, s))
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/ivaxer/go-xattr"
+)
+
+var (
+	errOutsideRoot = errors.New("path is outside of root")
+	errNamespace   = errors.New("attribute namespace is not allowed")
+	errReadOnly    = errors.New("server is read-only")
+)
+
+type server struct {
+	root       string
+	namespaces []string
+	readOnly   bool
+	mux        *http.ServeMux
+}
+
+type request struct {
+	Path  string `json:"path"`
+	Name  string `json:"name"`
+	Value []byte `json:"value"`
+}
+
+func newServer(root string, namespaces []string, readOnly bool) (*server, error) {
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+	if root, err = filepath.Abs(root); err != nil {
+		return nil, err
+	}
+
+	s := &server{root: root, namespaces: namespaces, readOnly: readOnly, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/v1/list", s.get(s.list))
+	s.mux.HandleFunc("/v1/get", s.get(s.getAttr))
+	s.mux.HandleFunc("/v1/dump", s.get(s.dump))
+	s.mux.HandleFunc("/v1/set", s.post(s.set))
+	s.mux.HandleFunc("/v1/remove", s.post(s.remove))
+	return s, nil
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// get adapts a handler of query requests.
+func (s *server) get(h func(request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		q := r.URL.Query()
+		v, err := h(request{Path: q.Get("path"), Name: q.Get("name")})
+		s.reply(w, v, err)
+	}
+}
+
+// post adapts a handler of modifying requests.
+func (s *server) post(h func(request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		if s.readOnly {
+			writeError(w, http.StatusForbidden, errReadOnly)
+			return
+		}
+
+		var req request
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		v, err := h(req)
+		s.reply(w, v, err)
+	}
+}
+
+func (s *server) reply(w http.ResponseWriter, v interface{}, err error) {
+	if err != nil {
+		writeError(w, statusOf(err), s.relative(err))
+		return
+	}
+	if v == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// relative rewrites the path of err relative to the root, so responses
+// don't disclose where the root is. Paths outside the root are dropped.
+func (s *server) relative(err error) error {
+	e, ok := err.(*os.PathError)
+	if !ok || !filepath.IsAbs(e.Path) {
+		return err
+	}
+	rel, rerr := filepath.Rel(s.root, e.Path)
+	if rerr != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return e.Err
+	}
+	return &os.PathError{Op: e.Op, Path: path.Clean("/" + filepath.ToSlash(rel)), Err: e.Err}
+}
+
+func statusOf(err error) int {
+	if e, ok := err.(*os.PathError); ok {
+		err = e.Err
+	}
+	switch {
+	case err == errOutsideRoot, err == errNamespace, os.IsPermission(err):
+		return http.StatusForbidden
+	case os.IsNotExist(err), xattr.IsNotExist(err):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// resolve maps a request path to a file below the root. Symbolic links
+// are resolved so they can't be used to escape the root.
+func (s *server) resolve(p string) (string, error) {
+	name := filepath.Join(s.root, filepath.FromSlash(path.Clean("/"+p)))
+	name, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(s.root, name)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", &os.PathError{Op: "resolve", Path: p, Err: errOutsideRoot}
+	}
+	return name, nil
+}
+
+// open opens the file a request path resolves to. Attributes are accessed
+// through the descriptor: a symbolic link swapped in after resolve could
+// redirect path based calls outside of the root.
+func (s *server) open(p string) (*os.File, error) {
+	name, err := s.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	// O_NONBLOCK keeps FIFOs from blocking the open.
+	f, err := os.OpenFile(name, os.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// A directory on the way may have been replaced since resolve, so
+	// check the file opened is the one the path resolves to now.
+	fi, err := f.Stat()
+	if err == nil {
+		var again string
+		if again, err = s.resolve(p); err == nil {
+			var fi2 os.FileInfo
+			if fi2, err = os.Stat(again); err == nil && !os.SameFile(fi, fi2) {
+				err = &os.PathError{Op: "resolve", Path: p, Err: errOutsideRoot}
+			}
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// get, list, set and remove work like the functions of the xattr package
+// on an open file.
+
+func get(f *os.File, attr string) ([]byte, error) {
+	size, err := fgetxattr(int(f.Fd()), attr, nil)
+	if err != nil {
+		return nil, &os.PathError{Op: "fgetxattr", Path: f.Name(), Err: err}
+	}
+	if size == 0 {
+		return []byte{}, nil
+	}
+	buf := make([]byte, size)
+	size, err = fgetxattr(int(f.Fd()), attr, buf)
+	if err != nil {
+		return nil, &os.PathError{Op: "fgetxattr", Path: f.Name(), Err: err}
+	}
+	return buf[:size], nil
+}
+
+func list(f *os.File) ([]string, error) {
+	size, err := flistxattr(int(f.Fd()), nil)
+	if err != nil {
+		return nil, &os.PathError{Op: "flistxattr", Path: f.Name(), Err: err}
+	}
+	if size == 0 {
+		return []string{}, nil
+	}
+	buf := make([]byte, size)
+	size, err = flistxattr(int(f.Fd()), buf)
+	if err != nil {
+		return nil, &os.PathError{Op: "flistxattr", Path: f.Name(), Err: err}
+	}
+	return strings.Split(strings.TrimSuffix(string(buf[:size]), "\x00"), "\x00"), nil
+}
+
+func set(f *os.File, attr string, value []byte) error {
+	if err := fsetxattr(int(f.Fd()), attr, value); err != nil {
+		return &os.PathError{Op: "fsetxattr", Path: f.Name(), Err: err}
+	}
+	return nil
+}
+
+func remove(f *os.File, attr string) error {
+	if err := fremovexattr(int(f.Fd()), attr); err != nil {
+		return &os.PathError{Op: "fremovexattr", Path: f.Name(), Err: err}
+	}
+	return nil
+}
+
+// allowed reports whether name is visible. Namespaces ending in "." allow
+// the attributes they prefix, others only the attribute of that name.
+func (s *server) allowed(name string) bool {
+	if len(s.namespaces) == 0 {
+		return true
+	}
+	for _, ns := range s.namespaces {
+		if ns == name || strings.HasSuffix(ns, ".") && strings.HasPrefix(name, ns) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *server) checkName(req request) error {
+	if req.Name == "" || !s.allowed(req.Name) {
+		return &os.PathError{Op: "xattr", Path: req.Path, Err: errNamespace}
+	}
+	return nil
+}
+
+func (s *server) names(f *os.File) ([]string, error) {
+	all, err := list(f)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, n := range all {
+		if s.allowed(n) {
+			names = append(names, n)
+		}
+	}
+	return names, nil
+}
+
+func (s *server) list(req request) (interface{}, error) {
+	f, err := s.open(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	names, err := s.names(f)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"path": req.Path, "names": names}, nil
+}
+
+func (s *server) getAttr(req request) (interface{}, error) {
+	if err := s.checkName(req); err != nil {
+		return nil, err
+	}
+	f, err := s.open(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	value, err := get(f, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return request{Path: req.Path, Name: req.Name, Value: value}, nil
+}
+
+func (s *server) dump(req request) (interface{}, error) {
+	f, err := s.open(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	names, err := s.names(f)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := make(map[string][]byte)
+	for _, n := range names {
+		value, err := get(f, n)
+		if err != nil {
+			if xattr.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		attrs[n] = value
+	}
+	return map[string]interface{}{"path": req.Path, "attrs": attrs}, nil
+}
+
+func (s *server) set(req request) (interface{}, error) {
+	if err := s.checkName(req); err != nil {
+		return nil, err
+	}
+	f, err := s.open(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return nil, set(f, req.Name, req.Value)
+}
+
+func (s *server) remove(req request) (interface{}, error) {
+	if err := s.checkName(req); err != nil {
+		return nil, err
+	}
+	f, err := s.open(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return nil, remove(f, req.Name)
+}
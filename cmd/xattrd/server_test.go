@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/ivaxer/go-xattr"
+	"github.com/ivaxer/go-xattr/xattrtest"
+)
+
+func setup(t *testing.T, readOnly bool) (*server, string) {
+	dir := xattrtest.TempDir(t)
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatalf("Mkdir() failed: %v", err)
+	}
+	for _, name := range []string{filepath.Join(root, "file"), filepath.Join(dir, "secret")} {
+		if err := ioutil.WriteFile(name, nil, 0644); err != nil {
+			t.Fatalf("WriteFile() failed: %v", err)
+		}
+	}
+	if err := os.Symlink("../secret", filepath.Join(root, "escape")); err != nil {
+		t.Fatalf("Symlink() failed: %v", err)
+	}
+
+	s, err := newServer(root, []string{"user."}, readOnly)
+	if err != nil {
+		t.Fatalf("newServer() failed: %v", err)
+	}
+	return s, dir
+}
+
+func do(t *testing.T, s *server, method, url string, body interface{}, expected int) map[string]interface{} {
+	var r *http.Request
+	if body != nil {
+		b, _ := json.Marshal(body)
+		r = httptest.NewRequest(method, url, bytes.NewReader(b))
+	} else {
+		r = httptest.NewRequest(method, url, nil)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	if w.Code != expected {
+		t.Fatalf("%s %s: status %d, expected %d: %s", method, url, w.Code, expected, w.Body)
+	}
+	var v map[string]interface{}
+	if w.Code != http.StatusNoContent {
+		if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+			t.Fatalf("%s %s: invalid JSON response: %v", method, url, err)
+		}
+	}
+	return v
+}
+
+func TestFlow(t *testing.T) {
+	s, dir := setup(t, false)
+
+	do(t, s, "POST", "/v1/set", request{Path: "/file", Name: "user.color", Value: []byte("red")}, http.StatusNoContent)
+
+	v := do(t, s, "GET", "/v1/get?path=file&name=user.color", nil, http.StatusOK)
+	if v["value"] != "cmVk" {
+		t.Errorf("get: unexpected response %v", v)
+	}
+
+	if err := xattr.Set(filepath.Join(dir, "root", "file"), "trusted.hidden", []byte("x")); err == nil {
+		defer xattr.Remove(filepath.Join(dir, "root", "file"), "trusted.hidden")
+	}
+	v = do(t, s, "GET", "/v1/list?path=file", nil, http.StatusOK)
+	if names, ok := v["names"].([]interface{}); !ok || len(names) != 1 || names[0] != "user.color" {
+		t.Errorf("list: unexpected response %v", v)
+	}
+
+	v = do(t, s, "GET", "/v1/dump?path=file", nil, http.StatusOK)
+	if attrs, ok := v["attrs"].(map[string]interface{}); !ok || attrs["user.color"] != "cmVk" {
+		t.Errorf("dump: unexpected response %v", v)
+	}
+
+	do(t, s, "POST", "/v1/remove", request{Path: "file", Name: "user.color"}, http.StatusNoContent)
+	do(t, s, "GET", "/v1/get?path=file&name=user.color", nil, http.StatusNotFound)
+	v = do(t, s, "GET", "/v1/list?path=missing", nil, http.StatusNotFound)
+	if msg, _ := v["error"].(string); strings.Contains(msg, dir) || !strings.Contains(msg, "/missing") {
+		t.Errorf("list: error discloses the root or lacks the path: %q", msg)
+	}
+	do(t, s, "GET", "/v1/set", nil, http.StatusMethodNotAllowed)
+}
+
+func TestConfinement(t *testing.T) {
+	s, _ := setup(t, false)
+
+	do(t, s, "GET", "/v1/list?path=escape", nil, http.StatusForbidden)
+	do(t, s, "POST", "/v1/set", request{Path: "escape", Name: "user.x"}, http.StatusForbidden)
+
+	// ".." is cleaned against the root and can't leave it.
+	do(t, s, "GET", "/v1/list?path=../secret", nil, http.StatusNotFound)
+
+	// Files are opened to be accessed; FIFOs must not block that.
+	if err := syscall.Mkfifo(filepath.Join(s.root, "fifo"), 0644); err != nil {
+		t.Fatalf("Mkfifo() failed: %v", err)
+	}
+	do(t, s, "GET", "/v1/list?path=fifo", nil, http.StatusOK)
+
+	do(t, s, "GET", "/v1/get?path=file&name=trusted.x", nil, http.StatusForbidden)
+	do(t, s, "POST", "/v1/set", request{Path: "file", Name: "security.x"}, http.StatusForbidden)
+}
+
+func TestAllowed(t *testing.T) {
+	s := &server{namespaces: []string{"user.app.", "user.tag"}}
+	for name, expected := range map[string]bool{
+		"user.app.color":    true,
+		"user.app":          false,
+		"user.application":  false,
+		"user.tag":          true,
+		"user.tags":         false,
+		"user.tag.x":        false,
+		"trusted.app.color": false,
+	} {
+		if got := s.allowed(name); got != expected {
+			t.Errorf("allowed(%q): got %v, expected %v", name, got, expected)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	s, _ := setup(t, true)
+
+	do(t, s, "POST", "/v1/set", request{Path: "file", Name: "user.x"}, http.StatusForbidden)
+	do(t, s, "POST", "/v1/remove", request{Path: "file", Name: "user.x"}, http.StatusForbidden)
+	do(t, s, "GET", "/v1/list?path=file", nil, http.StatusOK)
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ivaxer/go-xattr"
+	"github.com/ivaxer/go-xattr/treeop"
+)
+
+// filter selects the attributes to copy.
+type filter struct {
+	include []string
+	exclude []string
+}
+
+func (f filter) match(name string) bool {
+	return (len(f.include) == 0 || matchAny(f.include, name)) && !matchAny(f.exclude, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if p == name || strings.HasSuffix(p, ".") && strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+type copier struct {
+	filter     filter
+	verify     bool
+	progress   io.Writer
+	limiter    *treeop.Limiter
+	checkpoint *treeop.Checkpoint
+
+	files, bytes int64
+	errors       int
+	lastReport   time.Time
+}
+
+func (c *copier) fail(err error) {
+	c.errors++
+	if c.progress != nil {
+		fmt.Fprintln(c.progress)
+	}
+	fmt.Fprintf(os.Stderr, "xcp: %v\n", err)
+}
+
+func (c *copier) warn(err error) {
+	if c.progress != nil {
+		fmt.Fprintln(c.progress)
+	}
+	fmt.Fprintf(os.Stderr, "xcp: warning: %v\n", err)
+}
+
+// notSupported reports whether err means the file system has no extended
+// attributes, or not in the namespace used.
+func notSupported(err error) bool {
+	if e, ok := err.(*os.PathError); ok {
+		err = e.Err
+	}
+	return err == syscall.ENOTSUP || err == syscall.EOPNOTSUPP
+}
+
+// inside reports whether dst is src or below it, after resolving symbolic
+// links. Copying a directory there would recurse without end.
+func inside(dst, src string) (bool, error) {
+	s, err := resolve(src)
+	if err != nil {
+		return false, err
+	}
+	d, err := resolve(dst)
+	if err != nil {
+		return false, err
+	}
+	return d == s || strings.HasPrefix(d, s+string(filepath.Separator)), nil
+}
+
+// resolve returns the absolute path of name with symbolic links resolved.
+// If name doesn't exist, its parent directory is resolved instead.
+func resolve(name string) (string, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if !os.IsNotExist(err) {
+		return resolved, err
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(abs))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(abs)), nil
+}
+
+// copy copies src to dst, recursing into directories. rel identifies src
+// in the checkpoint. Only files and links are recorded there: directories
+// get their attributes and times after their entries, so they are copied
+// again when resuming below them. The checkpoint only records a prefix of
+// the walk, so nothing more is recorded after the first error and a resumed
+// copy retries everything from the failed path on.
+func (c *copier) copy(src, dst, rel string) {
+	fi, err := os.Lstat(src)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+	if c.checkpoint.Completed(rel) && !(fi.IsDir() && c.checkpoint.Within(rel)) {
+		return
+	}
+
+	var size int64
+	if fi.Mode().IsRegular() {
+		size = fi.Size()
+	}
+	c.limiter.Wait(size)
+
+	switch mode := fi.Mode(); {
+	case mode.IsRegular():
+		err = c.copyFile(src, dst, fi)
+	case mode.IsDir():
+		err = c.copyDir(src, dst, rel, fi)
+	case mode&os.ModeSymlink != 0:
+		err = copySymlink(src, dst)
+	default:
+		err = fmt.Errorf("%s: skipping %v", src, mode.Type())
+	}
+	if err != nil {
+		c.fail(err)
+		return
+	}
+	if !fi.IsDir() && c.errors == 0 {
+		if err := c.checkpoint.Done(rel); err != nil {
+			c.fail(err)
+		}
+	}
+	c.files++
+	c.report(false)
+}
+
+func (c *copier) copyFile(src, dst string, fi os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := removeOther(dst, 0); err != nil {
+		return err
+	}
+	// Owner write permission is needed until the attributes are set.
+	// O_NOFOLLOW guards against a link created since removeOther.
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|syscall.O_NOFOLLOW, 0600)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(out, in)
+	c.bytes += n
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := c.finish(src, dst, fi); err != nil {
+		return err
+	}
+	if c.verify {
+		return c.verifyFile(src, dst)
+	}
+	return nil
+}
+
+func (c *copier) copyDir(src, dst, rel string, fi os.FileInfo) error {
+	if err := removeOther(dst, os.ModeDir); err != nil {
+		return err
+	}
+	if err := os.Mkdir(dst, 0700); err != nil && !os.IsExist(err) {
+		return err
+	}
+
+	names, err := readDirNames(src)
+	if err != nil {
+		return err
+	}
+	// Sorted, so the order matches the checkpoint.
+	sort.Strings(names)
+	for _, name := range names {
+		c.copy(filepath.Join(src, name), filepath.Join(dst, name), rel+"/"+name)
+	}
+
+	// Set the times last, copying the entries changes them.
+	if err := c.finish(src, dst, fi); err != nil {
+		return err
+	}
+	if c.verify {
+		return c.verifyAttrs(src, dst)
+	}
+	return nil
+}
+
+// removeOther removes dst if it exists with a file type other than typ,
+// so that the copy replaces it instead of following a symbolic link there.
+func removeOther(dst string, typ os.FileMode) error {
+	fi, err := os.Lstat(dst)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeType == typ {
+		return nil
+	}
+	return os.Remove(dst)
+}
+
+func readDirNames(dir string) ([]string, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdirnames(-1)
+}
+
+func copySymlink(src, dst string) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Symlink(target, dst)
+}
+
+// finish copies the attributes, mode and times of src to dst.
+func (c *copier) finish(src, dst string, fi os.FileInfo) error {
+	if err := c.copyAttrs(src, dst); err != nil {
+		return err
+	}
+	if err := os.Chmod(dst, fi.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, atime(fi), fi.ModTime())
+}
+
+// copyAttrs copies the selected attributes of src to dst and removes the
+// selected attributes dst has but src doesn't. As with cp -a, attributes
+// a file system doesn't support are warned about and don't fail the copy.
+func (c *copier) copyAttrs(src, dst string) error {
+	attrs, err := c.attrs(src)
+	if notSupported(err) {
+		c.warn(err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	existing, err := c.attrs(dst)
+	if notSupported(err) {
+		if len(attrs) > 0 {
+			c.warn(err)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for name := range existing {
+		if _, ok := attrs[name]; !ok {
+			if err := xattr.Remove(dst, name); err != nil && !xattr.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	for name, value := range attrs {
+		if err := xattr.Set(dst, name, value); err != nil {
+			if notSupported(err) {
+				c.warn(fmt.Errorf("%v (%s)", err, name))
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// attrs returns the selected attributes of path.
+func (c *copier) attrs(path string) (map[string][]byte, error) {
+	names, err := xattr.List(path)
+	if err != nil {
+		return nil, err
+	}
+	attrs := make(map[string][]byte)
+	for _, name := range names {
+		if !c.filter.match(name) {
+			continue
+		}
+		v, err := xattr.Get(path, name)
+		if err != nil {
+			if xattr.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		attrs[name] = v
+	}
+	return attrs, nil
+}
+
+func (c *copier) verifyFile(src, dst string) error {
+	a, err := digest(src)
+	if err != nil {
+		return err
+	}
+	b, err := digest(dst)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(a, b) {
+		return fmt.Errorf("%s: verify: content differs from %s", dst, src)
+	}
+	return c.verifyAttrs(src, dst)
+}
+
+// verifyAttrs compares the selected attributes of src and dst. A file
+// system without attributes counts as having none.
+func (c *copier) verifyAttrs(src, dst string) error {
+	a, err := c.attrs(src)
+	if err != nil && !notSupported(err) {
+		return err
+	}
+	b, err := c.attrs(dst)
+	if err != nil && !notSupported(err) {
+		return err
+	}
+	if len(a) != len(b) {
+		return fmt.Errorf("%s: verify: %d attributes, expected %d", dst, len(b), len(a))
+	}
+	for name, v := range a {
+		if w, ok := b[name]; !ok || !bytes.Equal(v, w) {
+			return fmt.Errorf("%s: verify: attribute %s differs from %s", dst, name, src)
+		}
+	}
+	return nil
+}
+
+func digest(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// report prints the progress, at most every 200ms unless final is set.
+func (c *copier) report(final bool) {
+	if c.progress == nil || !final && time.Since(c.lastReport) < 200*time.Millisecond {
+		return
+	}
+	c.lastReport = time.Now()
+	fmt.Fprintf(c.progress, "\r%d files, %d bytes", c.files, c.bytes)
+}
+
+// done prints the final progress report.
+func (c *copier) done() {
+	if c.progress != nil {
+		c.report(true)
+		fmt.Fprintln(c.progress)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ivaxer/go-xattr"
+	"github.com/ivaxer/go-xattr/treeop"
+	"github.com/ivaxer/go-xattr/xattrtest"
+)
+
+func TestCopy(t *testing.T) {
+	dir := xattrtest.TempDir(t)
+
+	src := filepath.Join(dir, "src")
+	os.MkdirAll(filepath.Join(src, "sub"), 0755)
+	file := filepath.Join(src, "sub", "file")
+	if err := ioutil.WriteFile(file, []byte("data"), 0644); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+	os.Symlink("sub/file", filepath.Join(src, "link"))
+
+	xattr.Set(file, "user.keep", []byte("1"))
+	xattr.Set(file, "user.cache.hint", []byte("2"))
+	xattr.Set(filepath.Join(src, "sub"), "user.dir", []byte("3"))
+	os.Chmod(file, 0440)
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	os.Chtimes(file, mtime, mtime)
+	os.Chtimes(filepath.Join(src, "sub"), mtime, mtime)
+
+	c := &copier{filter: filter{exclude: []string{"user.cache."}}, verify: true}
+	dst := filepath.Join(dir, "dst")
+	c.copy(src, dst, "000000")
+	if c.errors != 0 {
+		t.Fatalf("copy(): %d errors", c.errors)
+	}
+	if c.files != 4 || c.bytes != 4 {
+		t.Errorf("copy(): got %d files, %d bytes", c.files, c.bytes)
+	}
+
+	copied := filepath.Join(dst, "sub", "file")
+	if b, _ := ioutil.ReadFile(copied); string(b) != "data" {
+		t.Errorf("copy(): got content %q", b)
+	}
+	fi, err := os.Stat(copied)
+	if err != nil {
+		t.Fatalf("Stat() failed: %v", err)
+	}
+	if fi.Mode().Perm() != 0440 || !fi.ModTime().Equal(mtime) {
+		t.Errorf("copy(): got mode %v, mtime %v", fi.Mode(), fi.ModTime())
+	}
+	if fi, _ := os.Stat(filepath.Join(dst, "sub")); !fi.ModTime().Equal(mtime) {
+		t.Errorf("copy(): got directory mtime %v", fi.ModTime())
+	}
+	if target, _ := os.Readlink(filepath.Join(dst, "link")); target != "sub/file" {
+		t.Errorf("copy(): got link target %q", target)
+	}
+
+	names, _ := xattr.List(copied)
+	if !reflect.DeepEqual(names, []string{"user.keep"}) {
+		t.Errorf("copy(): got attributes %v", names)
+	}
+	if v, _ := xattr.Get(filepath.Join(dst, "sub"), "user.dir"); string(v) != "3" {
+		t.Errorf("copy(): got directory attribute %q", v)
+	}
+
+	// Verification catches a modified copy.
+	os.Chmod(copied, 0644)
+	xattr.Set(copied, "user.keep", []byte("changed"))
+	if err := c.verifyFile(file, copied); err == nil {
+		t.Error("verifyFile(): expected error on modified attribute")
+	}
+}
+
+func TestResume(t *testing.T) {
+	dir := xattrtest.TempDir(t)
+
+	src := filepath.Join(dir, "src")
+	for _, name := range []string{"a/1", "a/2", "b/3"} {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, []byte(name), 0644)
+	}
+
+	// A previous run stopped after copying a/1.
+	name := filepath.Join(dir, "checkpoint")
+	ioutil.WriteFile(name, []byte(`{"job":"test","last":"000000/a/1"}`), 0644)
+	cp, err := treeop.OpenCheckpoint(name, "test")
+	if err != nil {
+		t.Fatalf("OpenCheckpoint() failed: %v", err)
+	}
+
+	c := &copier{checkpoint: cp, limiter: treeop.NewLimiter(1000, 0)}
+	dst := filepath.Join(dir, "dst")
+	c.copy(src, dst, "000000")
+	if c.errors != 0 {
+		t.Fatalf("copy(): %d errors", c.errors)
+	}
+
+	if _, err := os.Stat(filepath.Join(dst, "a", "1")); !os.IsNotExist(err) {
+		t.Errorf("copy(): completed file copied again: %v", err)
+	}
+	for _, name := range []string{"a/2", "b/3"} {
+		if b, _ := ioutil.ReadFile(filepath.Join(dst, filepath.FromSlash(name))); string(b) != name {
+			t.Errorf("copy(): %s: got %q", name, b)
+		}
+	}
+	if cp.Last() != "000000/b/3" {
+		t.Errorf("copy(): checkpoint at %q", cp.Last())
+	}
+}
+
+func TestResumeAfterError(t *testing.T) {
+	dir := xattrtest.TempDir(t)
+
+	src := filepath.Join(dir, "src")
+	for _, name := range []string{"a/1", "a/2", "b/3"} {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, []byte(name), 0644)
+	}
+
+	// A directory in the way of a/2 makes its copy fail.
+	dst := filepath.Join(dir, "dst")
+	blocker := filepath.Join(dst, "a", "2", "x")
+	os.MkdirAll(blocker, 0755)
+
+	name := filepath.Join(dir, "checkpoint")
+	cp, err := treeop.OpenCheckpoint(name, "test")
+	if err != nil {
+		t.Fatalf("OpenCheckpoint() failed: %v", err)
+	}
+	c := &copier{checkpoint: cp}
+	c.copy(src, dst, "000000")
+	if c.errors != 1 {
+		t.Fatalf("copy(): got %d errors, expected 1", c.errors)
+	}
+	if cp.Last() != "000000/a/1" {
+		t.Errorf("copy(): checkpoint at %q, expected %q", cp.Last(), "000000/a/1")
+	}
+	if err := cp.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	os.RemoveAll(filepath.Dir(blocker))
+	if cp, err = treeop.OpenCheckpoint(name, "test"); err != nil {
+		t.Fatalf("OpenCheckpoint() failed: %v", err)
+	}
+	c = &copier{checkpoint: cp}
+	c.copy(src, dst, "000000")
+	if c.errors != 0 {
+		t.Fatalf("copy(): %d errors after resuming", c.errors)
+	}
+	for _, name := range []string{"a/1", "a/2", "b/3"} {
+		if b, _ := ioutil.ReadFile(filepath.Join(dst, filepath.FromSlash(name))); string(b) != name {
+			t.Errorf("copy(): %s: got %q", name, b)
+		}
+	}
+}
+
+func TestReplaceLinks(t *testing.T) {
+	dir := xattrtest.TempDir(t)
+	src := filepath.Join(dir, "src")
+	os.MkdirAll(filepath.Join(src, "sub"), 0755)
+	ioutil.WriteFile(filepath.Join(src, "file"), []byte("data"), 0644)
+
+	// Links left in the destination must be replaced, not followed.
+	outside := filepath.Join(dir, "outside")
+	os.Mkdir(outside, 0755)
+	ioutil.WriteFile(filepath.Join(outside, "file"), []byte("keep"), 0644)
+	dst := filepath.Join(dir, "dst")
+	os.Mkdir(dst, 0755)
+	os.Symlink(filepath.Join(outside, "file"), filepath.Join(dst, "file"))
+	os.Symlink(outside, filepath.Join(dst, "sub"))
+
+	c := &copier{}
+	c.copy(src, dst, "")
+	if c.errors != 0 {
+		t.Fatalf("copy(): %d errors", c.errors)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(outside, "file")); string(b) != "keep" {
+		t.Errorf("copy(): wrote %q through a link", b)
+	}
+	for _, name := range []string{"file", "sub"} {
+		if fi, err := os.Lstat(filepath.Join(dst, name)); err != nil || fi.Mode()&os.ModeSymlink != 0 {
+			t.Errorf("copy(): %s not replaced: %v", name, err)
+		}
+	}
+}
+
+func TestInside(t *testing.T) {
+	dir := xattrtest.TempDir(t)
+	src := filepath.Join(dir, "a")
+	os.Mkdir(src, 0755)
+	os.Symlink(src, filepath.Join(dir, "link"))
+
+	for dst, expected := range map[string]bool{
+		src:                             true,
+		filepath.Join(src, "b"):         true,
+		filepath.Join(dir, "link", "b"): true,
+		filepath.Join(dir, "ab"):        false,
+		filepath.Join(dir, "b", "a"):    false,
+	} {
+		if got, err := inside(dst, src); got != expected {
+			t.Errorf("inside(%s, %s): got %v, %v, expected %v", dst, src, got, err, expected)
+		}
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	// procfs lists no attributes and refuses to set any.
+	const dst = "/proc/version"
+	if _, err := os.Stat(dst); err != nil {
+		t.Skip(err)
+	}
+	path := xattrtest.TempFile(t)
+	if err := xattr.Set(path, "user.keep", []byte("1")); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+
+	c := &copier{}
+	if err := c.copyAttrs(path, dst); err != nil {
+		t.Errorf("copyAttrs(): got %v, expected a warning only", err)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	f := filter{include: []string{"user.", "security.capability"}, exclude: []string{"user.tmp."}}
+	for name, expected := range map[string]bool{
+		"user.a":              true,
+		"user.tmp.x":          false,
+		"security.capability": true,
+		"security.selinux":    false,
+		"trusted.x":           false,
+	} {
+		if got := f.match(name); got != expected {
+			t.Errorf("match(%q): got %v, expected %v", name, got, expected)
+		}
+	}
+	if !(filter{}).match("trusted.x") {
+		t.Error("match(): empty filter should match everything")
+	}
+}
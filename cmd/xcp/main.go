@@ -0,0 +1,127 @@
+// Command xcp copies files and directory trees with their data, modes,
+// modification and access times, and extended attributes, behaving the
+// same on every platform this package supports.
+//
+// Usage:
+//
+//	xcp [-include NS] [-exclude NS] [-progress] [-verify]
+//	    [-ops N] [-bwlimit BYTES] [-checkpoint FILE] SRC... DST
+//
+// Directories are copied recursively. If DST is an existing directory, or
+// there are several sources, each source is copied into DST under its base
+// name; otherwise SRC is copied to DST. Symbolic links are copied as links
+// and not followed. Devices, sockets and named pipes are skipped with a
+// warning.
+//
+// -include and -exclude take comma separated lists of attributes; entries
+// ending in '.' match a namespace, e.g. -exclude security.selinux,trusted.
+// Without -include every attribute is copied. Attributes of symbolic links
+// are not copied.
+//
+// -verify reads each copied file back and compares its content and
+// attributes with the source. -progress reports the number of files and
+// bytes copied on standard error.
+//
+// -ops and -bwlimit limit the files and bytes copied per second. With
+// -checkpoint, progress is recorded in FILE; running the same command
+// again after an interruption skips the files already copied. Nothing is
+// recorded after the first error, so the failed files are retried. The
+// file is removed when the copy completes without errors.
+//
+// Attributes the source or destination file system doesn't support are
+// reported as warnings. Copying a directory into itself is refused.
+//
+// Errors are reported as they occur and the copy goes on; xcp exits with
+// status 1 if any occurred.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ivaxer/go-xattr/treeop"
+)
+
+func main() {
+	include := flag.String("include", "", "comma separated attributes or namespaces to copy")
+	exclude := flag.String("exclude", "", "comma separated attributes or namespaces not to copy")
+	progress := flag.Bool("progress", false, "report progress on standard error")
+	verify := flag.Bool("verify", false, "compare copies with their sources")
+	ops := flag.Float64("ops", 0, "maximum files per second, 0 for no limit")
+	bwlimit := flag.Float64("bwlimit", 0, "maximum bytes per second, 0 for no limit")
+	checkpoint := flag.String("checkpoint", "", "record progress in `file` to resume from it")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: xcp [flags] SRC... DST")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c := &copier{
+		filter:  filter{include: split(*include), exclude: split(*exclude)},
+		verify:  *verify,
+		limiter: treeop.NewLimiter(*ops, *bwlimit),
+	}
+	if *progress {
+		c.progress = os.Stderr
+	}
+
+	srcs, dst := flag.Args()[:flag.NArg()-1], flag.Arg(flag.NArg()-1)
+	into := len(srcs) > 1
+	if fi, err := os.Stat(dst); err == nil && fi.IsDir() {
+		into = true
+	} else if into {
+		fmt.Fprintf(os.Stderr, "xcp: %s is not a directory\n", dst)
+		os.Exit(1)
+	}
+
+	if *checkpoint != "" {
+		var err error
+		job := fmt.Sprintf("xcp %q", flag.Args())
+		if c.checkpoint, err = treeop.OpenCheckpoint(*checkpoint, job); err != nil {
+			fmt.Fprintf(os.Stderr, "xcp: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	for i, src := range srcs {
+		target := dst
+		if into {
+			target = filepath.Join(dst, filepath.Base(src))
+		}
+		if loop, err := inside(target, src); err != nil || loop {
+			if err == nil {
+				err = fmt.Errorf("cannot copy %s into itself, %s", src, target)
+			}
+			c.fail(err)
+			continue
+		}
+		// Number the sources so they sort in argument order.
+		c.copy(src, target, fmt.Sprintf("%06d", i))
+	}
+	c.done()
+
+	if err := c.checkpoint.Save(); err != nil {
+		c.fail(err)
+	} else if c.errors == 0 {
+		if err := c.checkpoint.Remove(); err != nil {
+			c.fail(err)
+		}
+	}
+	if c.errors > 0 {
+		os.Exit(1)
+	}
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
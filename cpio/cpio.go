@@ -0,0 +1,352 @@
+// Package cpio reads and writes cpio archives in the SVR4 "newc" format
+// used by initramfs images and rpm payloads, carrying extended attributes
+// alongside the files.
+//
+// The newc header has no room for attributes, so they travel in AppleDouble
+// sidecar members as bsdcpio on macOS writes them: the attributes of
+// "dir/name" are stored in a regular file "dir/._name" placed just before
+// it, see package appledouble. Writer emits a sidecar for every header with
+// Xattrs and Reader folds sidecars into the Xattrs of the member that
+// follows them. Tools unaware of the convention extract sidecars as plain
+// files, which appledouble.Decode and appledouble.Restore can apply later.
+package cpio
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"github.com/ivaxer/go-xattr/appledouble"
+)
+
+const (
+	magicNewc  = "070701"
+	magicCRC   = "070702"
+	headerSize = 110
+	trailer    = "TRAILER!!!"
+
+	// maxSidecarSize bounds the sidecars read into memory.
+	maxSidecarSize = 64 << 20
+)
+
+// File type bits of Header.Mode.
+const (
+	TypeMask    = 0170000
+	TypeSocket  = 0140000
+	TypeSymlink = 0120000
+	TypeReg     = 0100000
+	TypeBlock   = 0060000
+	TypeDir     = 0040000
+	TypeChar    = 0020000
+	TypeFifo    = 0010000
+)
+
+var (
+	// ErrHeader is returned when a member header is invalid.
+	ErrHeader = errors.New("cpio: invalid header")
+	// ErrWriteTooLong is returned when more data is written to a member
+	// than its header declares.
+	ErrWriteTooLong = errors.New("cpio: write too long")
+)
+
+// Header is the header of a cpio member.
+type Header struct {
+	Name  string
+	Mode  int64 // permission and type bits, e.g. TypeReg|0644
+	Uid   int
+	Gid   int
+	Nlink int
+	Mtime time.Time
+	Size  int64 // data length; the link target length for symlinks
+	Ino   int64
+
+	Devmajor  int64
+	Devminor  int64
+	Rdevmajor int64 // device number of character and block devices
+	Rdevminor int64
+
+	// Xattrs holds the extended attributes of the member.
+	Xattrs map[string][]byte
+}
+
+// Writer writes a cpio archive.
+type Writer struct {
+	w         io.Writer
+	remaining int64 // data bytes left in the current member
+	pad       int64
+	ino       int64
+	err       error
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WriteHeader writes hdr and prepares to accept its data. A sidecar member
+// carrying hdr.Xattrs is written first. Members with a zero Ino are given
+// unique inode numbers.
+func (tw *Writer) WriteHeader(hdr *Header) error {
+	if err := tw.finish(); err != nil {
+		return err
+	}
+
+	if len(hdr.Xattrs) > 0 {
+		b, err := appledouble.Encode(hdr.Xattrs)
+		if err != nil {
+			return err
+		}
+		sidecar := &Header{
+			Name:  appledouble.SidecarName(hdr.Name),
+			Mode:  TypeReg | 0644,
+			Uid:   hdr.Uid,
+			Gid:   hdr.Gid,
+			Mtime: hdr.Mtime,
+			Size:  int64(len(b)),
+		}
+		if err := tw.writeHeader(sidecar, tw.nextIno()); err != nil {
+			return err
+		}
+		if _, err := tw.Write(b); err != nil {
+			return err
+		}
+		if err := tw.finish(); err != nil {
+			return err
+		}
+	}
+	ino := hdr.Ino
+	if ino == 0 {
+		ino = tw.nextIno()
+	}
+	return tw.writeHeader(hdr, ino)
+}
+
+func (tw *Writer) nextIno() int64 {
+	tw.ino++
+	return tw.ino
+}
+
+func (tw *Writer) writeHeader(hdr *Header, ino int64) error {
+	nlink := hdr.Nlink
+	if nlink == 0 {
+		nlink = 1
+		if hdr.Mode&TypeMask == TypeDir {
+			nlink = 2
+		}
+	}
+	var mtime int64
+	if !hdr.Mtime.IsZero() {
+		mtime = hdr.Mtime.Unix()
+	}
+
+	var b bytes.Buffer
+	b.WriteString(magicNewc)
+	for _, v := range []int64{
+		ino, hdr.Mode, int64(hdr.Uid), int64(hdr.Gid), int64(nlink), mtime, hdr.Size,
+		hdr.Devmajor, hdr.Devminor, hdr.Rdevmajor, hdr.Rdevminor,
+		int64(len(hdr.Name) + 1), 0,
+	} {
+		if v < 0 || v > 0xffffffff {
+			return ErrHeader
+		}
+		fmt.Fprintf(&b, "%08x", v)
+	}
+	b.WriteString(hdr.Name)
+	b.WriteByte(0)
+	b.Write(make([]byte, padding(int64(b.Len()))))
+
+	if _, err := tw.w.Write(b.Bytes()); err != nil {
+		tw.err = err
+		return err
+	}
+	tw.remaining = hdr.Size
+	tw.pad = padding(hdr.Size)
+	return nil
+}
+
+// Write writes data of the current member.
+func (tw *Writer) Write(p []byte) (int, error) {
+	if tw.err != nil {
+		return 0, tw.err
+	}
+	var err error
+	if int64(len(p)) > tw.remaining {
+		p, err = p[:tw.remaining], ErrWriteTooLong
+	}
+	n, werr := tw.w.Write(p)
+	tw.remaining -= int64(n)
+	if werr != nil {
+		tw.err = werr
+		return n, werr
+	}
+	return n, err
+}
+
+// finish pads the current member.
+func (tw *Writer) finish() error {
+	if tw.err != nil {
+		return tw.err
+	}
+	if tw.remaining > 0 {
+		return fmt.Errorf("cpio: missed writing %d bytes", tw.remaining)
+	}
+	if tw.pad > 0 {
+		if _, err := tw.w.Write(make([]byte, tw.pad)); err != nil {
+			tw.err = err
+			return err
+		}
+		tw.pad = 0
+	}
+	return nil
+}
+
+// Close writes the trailer. It doesn't close the underlying writer.
+func (tw *Writer) Close() error {
+	if err := tw.finish(); err != nil {
+		return err
+	}
+	if err := tw.writeHeader(&Header{Name: trailer, Nlink: 1}, 0); err != nil {
+		return err
+	}
+	return tw.finish()
+}
+
+// Reader reads a cpio archive.
+type Reader struct {
+	r         *bufio.Reader
+	remaining int64
+	pad       int64
+	pending   map[string]map[string][]byte
+}
+
+// NewReader returns a Reader reading from r. Both the newc and the crc
+// variant of the format are accepted; checksums are not verified.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Next advances to the next member and returns its header. It returns
+// io.EOF at the trailer. Sidecar members that decode as AppleDouble are
+// consumed and their attributes returned in the Xattrs of their target.
+func (cr *Reader) Next() (*Header, error) {
+	for {
+		if _, err := io.CopyN(ioutil.Discard, cr.r, cr.remaining+cr.pad); err != nil {
+			return nil, unexpected(err)
+		}
+		cr.remaining, cr.pad = 0, 0
+
+		hdr, err := cr.readHeader()
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Name == trailer {
+			return nil, io.EOF
+		}
+
+		target, ok := appledouble.TargetName(hdr.Name)
+		if ok && hdr.Mode&TypeMask == TypeReg && hdr.Size <= maxSidecarSize {
+			b, err := ioutil.ReadAll(cr)
+			if err != nil {
+				return nil, err
+			}
+			if attrs, err := appledouble.Decode(b); err == nil {
+				if cr.pending == nil {
+					cr.pending = make(map[string]map[string][]byte)
+				}
+				cr.pending[target] = attrs
+				continue
+			}
+			// Not AppleDouble after all; hand the member back as is.
+			cr.r = bufio.NewReader(io.MultiReader(bytes.NewReader(b), cr.r))
+			cr.remaining = hdr.Size
+		}
+
+		if attrs, ok := cr.pending[hdr.Name]; ok {
+			hdr.Xattrs = attrs
+			delete(cr.pending, hdr.Name)
+		}
+		return hdr, nil
+	}
+}
+
+func (cr *Reader) readHeader() (*Header, error) {
+	var b [headerSize]byte
+	if _, err := io.ReadFull(cr.r, b[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, unexpected(err)
+	}
+	if m := string(b[:6]); m != magicNewc && m != magicCRC {
+		return nil, ErrHeader
+	}
+
+	var f [13]int64
+	for i := range f {
+		v, err := strconv.ParseUint(string(b[6+8*i:14+8*i]), 16, 32)
+		if err != nil {
+			return nil, ErrHeader
+		}
+		f[i] = int64(v)
+	}
+	nameSize := f[11]
+	if nameSize == 0 || nameSize > 4096 {
+		return nil, ErrHeader
+	}
+	name := make([]byte, nameSize+padding(headerSize+nameSize))
+	if _, err := io.ReadFull(cr.r, name); err != nil {
+		return nil, unexpected(err)
+	}
+
+	hdr := &Header{
+		Name:      string(bytes.TrimRight(name[:nameSize], "\x00")),
+		Ino:       f[0],
+		Mode:      f[1],
+		Uid:       int(f[2]),
+		Gid:       int(f[3]),
+		Nlink:     int(f[4]),
+		Mtime:     time.Unix(f[5], 0),
+		Size:      f[6],
+		Devmajor:  f[7],
+		Devminor:  f[8],
+		Rdevmajor: f[9],
+		Rdevminor: f[10],
+	}
+	cr.remaining = hdr.Size
+	cr.pad = padding(hdr.Size)
+	return hdr, nil
+}
+
+// Read reads data of the current member. It returns io.EOF at the end of
+// the member.
+func (cr *Reader) Read(p []byte) (int, error) {
+	if cr.remaining == 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > cr.remaining {
+		p = p[:cr.remaining]
+	}
+	n, err := cr.r.Read(p)
+	cr.remaining -= int64(n)
+	if err == io.EOF && cr.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// padding returns the number of bytes aligning n to 4.
+func padding(n int64) int64 {
+	return -n & 3
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
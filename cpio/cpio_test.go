@@ -0,0 +1,134 @@
+package cpio
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ivaxer/go-xattr/appledouble"
+)
+
+type member struct {
+	hdr  Header
+	data string
+}
+
+func TestRoundTrip(t *testing.T) {
+	mtime := time.Unix(1600000000, 0)
+	members := []member{
+		{Header{Name: "etc", Mode: TypeDir | 0755, Mtime: mtime}, ""},
+		{Header{Name: "etc/hosts", Mode: TypeReg | 0644, Mtime: mtime, Size: 10, Xattrs: map[string][]byte{
+			"security.selinux": []byte("system_u:object_r:net_conf_t:s0\x00"),
+			"user.comment":     []byte("local"),
+		}}, "127.0.0.1\n"},
+		{Header{Name: "bin/ping", Mode: TypeReg | 0755, Uid: 0, Gid: 0, Mtime: mtime, Size: 3, Xattrs: map[string][]byte{
+			"security.capability": {0, 0, 0, 2, 0, 0x20, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		}}, "elf"},
+		{Header{Name: "bin/sh", Mode: TypeSymlink | 0777, Mtime: mtime, Size: 4}, "dash"},
+		{Header{Name: "._notes", Mode: TypeReg | 0644, Mtime: mtime, Size: 5}, "plain"},
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, m := range members {
+		hdr := m.hdr
+		if err := w.WriteHeader(&hdr); err != nil {
+			t.Fatalf("WriteHeader(%q) failed: %v", hdr.Name, err)
+		}
+		if _, err := io.WriteString(w, m.data); err != nil {
+			t.Fatalf("Write(%q) failed: %v", hdr.Name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if buf.Len()%4 != 0 {
+		t.Errorf("archive length %d is not aligned", buf.Len())
+	}
+
+	r := NewReader(&buf)
+	for _, m := range members {
+		hdr, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next() failed: %v", err)
+		}
+		if hdr.Name != m.hdr.Name || hdr.Mode != m.hdr.Mode || hdr.Size != m.hdr.Size || !hdr.Mtime.Equal(mtime) {
+			t.Errorf("Next(): got %+v, expected %+v", hdr, m.hdr)
+		}
+		if len(hdr.Xattrs) != 0 || len(m.hdr.Xattrs) != 0 {
+			if !reflect.DeepEqual(hdr.Xattrs, m.hdr.Xattrs) {
+				t.Errorf("Next(%q): got xattrs %q, expected %q", hdr.Name, hdr.Xattrs, m.hdr.Xattrs)
+			}
+		}
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("Read(%q) failed: %v", hdr.Name, err)
+		}
+		if string(data) != m.data {
+			t.Errorf("Read(%q): got %q, expected %q", hdr.Name, data, m.data)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next(): got %v, expected io.EOF", err)
+	}
+}
+
+func TestSidecarMember(t *testing.T) {
+	// Plain cpio tools see the sidecar as an ordinary file.
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	hdr := &Header{Name: "a/b", Mode: TypeReg | 0600, Xattrs: map[string][]byte{"user.x": []byte("y")}}
+	if err := w.WriteHeader(hdr); err != nil {
+		t.Fatalf("WriteHeader() failed: %v", err)
+	}
+	w.Close()
+
+	r := NewReader(bytes.NewReader(buf.Bytes()))
+	raw, err := r.readHeader()
+	if err != nil {
+		t.Fatalf("readHeader() failed: %v", err)
+	}
+	if raw.Name != "a/._b" {
+		t.Fatalf("first member: got %q, expected %q", raw.Name, "a/._b")
+	}
+	b, _ := ioutil.ReadAll(r)
+	attrs, err := appledouble.Decode(b)
+	if err != nil {
+		t.Fatalf("Decode() failed: %v", err)
+	}
+	if string(attrs["user.x"]) != "y" {
+		t.Errorf("sidecar: got %q", attrs)
+	}
+}
+
+func TestWriteTooLong(t *testing.T) {
+	w := NewWriter(ioutil.Discard)
+	if err := w.WriteHeader(&Header{Name: "f", Mode: TypeReg, Size: 1}); err != nil {
+		t.Fatalf("WriteHeader() failed: %v", err)
+	}
+	if _, err := w.Write([]byte("ab")); err != ErrWriteTooLong {
+		t.Errorf("Write(): got %v, expected ErrWriteTooLong", err)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.WriteHeader(&Header{Name: "f", Mode: TypeReg, Size: 100})
+	w.Write(make([]byte, 100))
+	w.Close()
+
+	r := NewReader(bytes.NewReader(buf.Bytes()[:150]))
+	if _, err := r.Next(); err != nil {
+		t.Fatalf("Next() failed: %v", err)
+	}
+	if _, err := ioutil.ReadAll(r); err != io.ErrUnexpectedEOF {
+		t.Errorf("Read(): got %v, expected io.ErrUnexpectedEOF", err)
+	}
+	if _, err := NewReader(bytes.NewReader([]byte("070707"))).Next(); err == nil {
+		t.Error("Next(): expected error on short header")
+	}
+}
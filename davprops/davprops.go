@@ -0,0 +1,177 @@
+// Package davprops stores WebDAV dead properties in extended attributes.
+//
+// Each property is kept in its own attribute. The attribute name encodes
+// the XML name of the property as
+//
+//	user.webdav.{namespace}local
+//
+// with both parts escaped using URL query escaping, and the value is the
+// property element serialized as XML, so the language and inner XML of the
+// property round-trip unchanged. Attribute names are limited to 255
+// bytes, so long namespaces leave little room for the local name; Patch
+// rejects properties whose attribute name would be longer.
+//
+// The types mirror those of golang.org/x/net/webdav and Store has the
+// DeadProps and Patch methods of webdav.DeadPropsHolder, so wrapping it
+// into a webdav.File takes a few lines of field-by-field conversion
+// without this package depending on x/net.
+package davprops
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/ivaxer/go-xattr"
+)
+
+// Prefix is the prefix of attribute names holding properties.
+const Prefix = "user.webdav."
+
+// maxAttrName is XATTR_NAME_MAX, the longest attribute name Linux accepts.
+const maxAttrName = 255
+
+// ErrNameTooLong is returned by Patch for a property whose attribute name
+// would exceed the length limit of attribute names.
+var ErrNameTooLong = errors.New("davprops: property name too long for an attribute name")
+
+// Property is a WebDAV property, like webdav.Property.
+type Property struct {
+	XMLName  xml.Name
+	Lang     string `xml:"xml:lang,attr,omitempty"`
+	InnerXML []byte `xml:",innerxml"`
+}
+
+// storedProperty is the form of Property kept in attributes. Unlike the
+// xml:lang tag of Property, the namespace URI in the Lang tag makes the
+// attribute survive unmarshaling.
+type storedProperty struct {
+	XMLName  xml.Name
+	Lang     string `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	InnerXML []byte `xml:",innerxml"`
+}
+
+// Proppatch is a set of property changes, like webdav.Proppatch.
+type Proppatch struct {
+	Remove bool
+	Props  []Property
+}
+
+// Propstat is the status of a set of properties, like webdav.Propstat.
+type Propstat struct {
+	Props               []Property
+	Status              int
+	XMLError            string
+	ResponseDescription string
+}
+
+// AttrName returns the attribute name the property name is stored under.
+func AttrName(name xml.Name) string {
+	return Prefix + "{" + url.QueryEscape(name.Space) + "}" + url.QueryEscape(name.Local)
+}
+
+// PropName returns the property name stored under the attribute attr. It
+// reports false if attr doesn't hold a property.
+func PropName(attr string) (xml.Name, bool) {
+	if !strings.HasPrefix(attr, Prefix+"{") {
+		return xml.Name{}, false
+	}
+	s := attr[len(Prefix)+1:]
+	i := strings.IndexByte(s, '}')
+	if i < 0 {
+		return xml.Name{}, false
+	}
+
+	space, err := url.QueryUnescape(s[:i])
+	if err != nil {
+		return xml.Name{}, false
+	}
+	local, err := url.QueryUnescape(s[i+1:])
+	if err != nil || local == "" {
+		return xml.Name{}, false
+	}
+	return xml.Name{Space: space, Local: local}, true
+}
+
+// Store holds the dead properties of the file at Path.
+type Store struct {
+	Path string
+}
+
+// DeadProps returns the dead properties of the file.
+func (s Store) DeadProps() (map[xml.Name]Property, error) {
+	attrs, err := xattr.List(s.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	props := make(map[xml.Name]Property)
+	for _, attr := range attrs {
+		name, ok := PropName(attr)
+		if !ok {
+			continue
+		}
+
+		b, err := xattr.Get(s.Path, attr)
+		if err != nil {
+			if xattr.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		var p storedProperty
+		if err := xml.Unmarshal(b, &p); err != nil {
+			return nil, &os.PathError{Op: "getxattr", Path: s.Path, Err: fmt.Errorf("davprops: invalid property %s: %v", attr, err)}
+		}
+		props[name] = Property{XMLName: name, Lang: p.Lang, InnerXML: p.InnerXML}
+	}
+	return props, nil
+}
+
+// Patch applies the changes in patches in order. Removing a property that
+// doesn't exist is not an error. Like the in-memory implementation of
+// x/net/webdav it reports all properties with http.StatusOK on success and
+// returns an error, which makes the server answer 500, on failure. Names
+// too long to store fail the patch with ErrNameTooLong before any change.
+func (s Store) Patch(patches []Proppatch) ([]Propstat, error) {
+	for _, patch := range patches {
+		for _, p := range patch.Props {
+			if !patch.Remove && len(AttrName(p.XMLName)) > maxAttrName {
+				return nil, &os.PathError{Op: "setxattr", Path: s.Path, Err: ErrNameTooLong}
+			}
+		}
+	}
+
+	pstat := Propstat{Status: http.StatusOK}
+	for _, patch := range patches {
+		for _, p := range patch.Props {
+			pstat.Props = append(pstat.Props, Property{XMLName: p.XMLName})
+
+			attr := AttrName(p.XMLName)
+			if patch.Remove {
+				if len(attr) > maxAttrName {
+					// It can't have been stored.
+					continue
+				}
+				if err := xattr.Remove(s.Path, attr); err != nil && !xattr.IsNotExist(err) {
+					return nil, err
+				}
+				continue
+			}
+
+			b, err := xml.Marshal(storedProperty(p))
+			if err != nil {
+				return nil, err
+			}
+			if err := xattr.Set(s.Path, attr, b); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return []Propstat{pstat}, nil
+}
@@ -0,0 +1,96 @@
+package davprops
+
+import (
+	"encoding/xml"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ivaxer/go-xattr/xattrtest"
+)
+
+func TestAttrName(t *testing.T) {
+	names := []xml.Name{
+		{Space: "DAV:", Local: "displayname"},
+		{Space: "http://example.com/ns/{x}", Local: "color"},
+		{Space: "", Local: "plain"},
+	}
+
+	for _, name := range names {
+		attr := AttrName(name)
+		got, ok := PropName(attr)
+		if !ok || got != name {
+			t.Errorf("PropName(%q): got %v %v, expected %v", attr, got, ok, name)
+		}
+	}
+
+	for _, attr := range []string{"user.mime_type", "user.webdav.", "user.webdav.{ns", "user.webdav.{ns}", "user.webdav.{%zz}x"} {
+		if _, ok := PropName(attr); ok {
+			t.Errorf("PropName(%q): expected false", attr)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := xattrtest.TempFile(t)
+
+	s := Store{Path: path}
+	color := Property{
+		XMLName:  xml.Name{Space: "http://example.com/ns", Local: "color"},
+		Lang:     "en",
+		InnerXML: []byte(`<x:shade xmlns:x="http://example.com/ns">dark</x:shade> red`),
+	}
+	author := Property{
+		XMLName:  xml.Name{Space: "DAV:", Local: "author"},
+		InnerXML: []byte("Jane"),
+	}
+
+	pstats, err := s.Patch([]Proppatch{{Props: []Property{color, author}}})
+	if err != nil {
+		t.Fatalf("Patch() failed: %v", err)
+	}
+	if len(pstats) != 1 || pstats[0].Status != http.StatusOK || len(pstats[0].Props) != 2 {
+		t.Errorf("Patch(): unexpected result %+v", pstats)
+	}
+
+	props, err := s.DeadProps()
+	if err != nil {
+		t.Fatalf("DeadProps() failed: %v", err)
+	}
+	if len(props) != 2 {
+		t.Fatalf("DeadProps(): got %d properties, expected 2", len(props))
+	}
+	got := props[color.XMLName]
+	if got.Lang != color.Lang || string(got.InnerXML) != string(color.InnerXML) {
+		t.Errorf("DeadProps(): got %+v, expected %+v", got, color)
+	}
+
+	_, err = s.Patch([]Proppatch{{Remove: true, Props: []Property{{XMLName: author.XMLName}, {XMLName: xml.Name{Local: "missing"}}}}})
+	if err != nil {
+		t.Fatalf("Patch(remove) failed: %v", err)
+	}
+	if props, _ = s.DeadProps(); len(props) != 1 {
+		t.Errorf("DeadProps(): got %d properties after remove, expected 1", len(props))
+	}
+}
+
+func TestLongName(t *testing.T) {
+	path := xattrtest.TempFile(t)
+
+	s := Store{Path: path}
+	short := Property{XMLName: xml.Name{Space: "DAV:", Local: "author"}, InnerXML: []byte("Jane")}
+	long := Property{XMLName: xml.Name{Space: "http://example.com/" + strings.Repeat("ns/", 80), Local: "color"}}
+
+	_, err := s.Patch([]Proppatch{{Props: []Property{short, long}}})
+	if e, ok := err.(*os.PathError); !ok || e.Err != ErrNameTooLong {
+		t.Fatalf("Patch(): got %v, expected %v", err, ErrNameTooLong)
+	}
+	if props, _ := s.DeadProps(); len(props) != 0 {
+		t.Errorf("DeadProps(): got %d properties after failed patch, expected 0", len(props))
+	}
+
+	if _, err := s.Patch([]Proppatch{{Remove: true, Props: []Property{long}}}); err != nil {
+		t.Errorf("Patch(remove) failed: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package describe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ivaxer/go-xattr/gluster"
+	"github.com/ivaxer/go-xattr/internal/posixacl"
+	"github.com/ivaxer/go-xattr/internal/vfscap"
+	"github.com/ivaxer/go-xattr/lustre"
+	"github.com/ivaxer/go-xattr/ntacl"
+)
+
+func init() {
+	builtins := []struct {
+		pattern string
+		decoder Decoder
+	}{
+		{posixacl.AccessAttr, describeACL},
+		{posixacl.DefaultAttr, describeACL},
+		{vfscap.Attr, describeCapability},
+		{"security.selinux", describeLabel},
+		{"security.SMACK64*", describeLabel},
+		{"security.apparmor", describeLabel},
+		{ntacl.CIFSAttr, describeNTACL(ntacl.Parse)},
+		{ntacl.SambaAttr, describeNTACL(ntacl.ParseNTACL)},
+		{"com.apple.FinderInfo", describeFinderInfo},
+		{"com.apple.quarantine", describeQuarantine},
+		{"com.apple.metadata:*", describePlist},
+		{gluster.GFIDAttr, Stringer(gluster.Decode)},
+		{gluster.VolumeIDAttr, Stringer(gluster.Decode)},
+		{gluster.DHTAttr, Stringer(gluster.Decode)},
+		{gluster.AFRPrefix + "*", Stringer(gluster.Decode)},
+		{lustre.LOVAttr, Stringer(lustre.Decode)},
+		{lustre.TrustedLOVAttr, Stringer(lustre.Decode)},
+		{lustre.LMAAttr, Stringer(lustre.Decode)},
+	}
+	for _, b := range builtins {
+		Default.Register(b.pattern, b.decoder)
+	}
+}
+
+func describeACL(name string, value []byte) (string, error) {
+	acl, err := posixacl.Parse(value)
+	if err != nil {
+		return "", err
+	}
+	return acl.String(), nil
+}
+
+func describeCapability(name string, value []byte) (string, error) {
+	c, err := vfscap.Parse(value)
+	if err != nil {
+		return "", err
+	}
+	return c.String(), nil
+}
+
+// describeLabel describes LSM labels, which are NUL terminated strings.
+func describeLabel(name string, value []byte) (string, error) {
+	value = bytes.TrimRight(value, "\x00")
+	if !isText(value) {
+		return "", fmt.Errorf("describe: binary label")
+	}
+	return string(value), nil
+}
+
+func describeNTACL(parse func([]byte) (*ntacl.SecurityDescriptor, error)) Decoder {
+	return func(name string, value []byte) (string, error) {
+		sd, err := parse(value)
+		if err != nil {
+			return "", err
+		}
+		return sd.String(), nil
+	}
+}
+
+// Finder label colors, indexed by bits 1-3 of the Finder flags.
+var finderColors = []string{"none", "gray", "green", "purple", "blue", "yellow", "red", "orange"}
+
+// describeFinderInfo describes the 32 byte FinderInfo of files: type and
+// creator codes followed by the Finder flags.
+func describeFinderInfo(name string, value []byte) (string, error) {
+	if len(value) != 32 {
+		return "", fmt.Errorf("describe: invalid FinderInfo length %d", len(value))
+	}
+
+	flags := binary.BigEndian.Uint16(value[8:])
+	return fmt.Sprintf("type=%s creator=%s flags=0x%04x color=%s",
+		fourCC(value[0:4]), fourCC(value[4:8]), flags, finderColors[flags>>1&7]), nil
+}
+
+func fourCC(b []byte) string {
+	if bytes.Equal(b, []byte{0, 0, 0, 0}) {
+		return "''"
+	}
+	return strconv.Quote(string(b))
+}
+
+// describeQuarantine describes com.apple.quarantine, which holds
+// "flags;hex timestamp;agent;event UUID".
+func describeQuarantine(name string, value []byte) (string, error) {
+	fields := strings.Split(string(bytes.TrimRight(value, "\x00")), ";")
+	if len(fields) < 3 {
+		return "", fmt.Errorf("describe: invalid quarantine value")
+	}
+
+	ts, err := strconv.ParseInt(fields[1], 16, 64)
+	if err != nil {
+		return "", err
+	}
+	s := fmt.Sprintf("flags=%s time=%s agent=%q", fields[0],
+		time.Unix(ts, 0).UTC().Format(time.RFC3339), fields[2])
+	if len(fields) > 3 && fields[3] != "" {
+		s += " event=" + fields[3]
+	}
+	return s, nil
+}
+
+// describePlist describes com.apple.metadata attributes, which are
+// property lists, usually in binary form.
+func describePlist(name string, value []byte) (string, error) {
+	if bytes.HasPrefix(value, []byte("bplist00")) {
+		return fmt.Sprintf("binary property list, %d bytes", len(value)), nil
+	}
+	if bytes.HasPrefix(value, []byte("<?xml")) {
+		return Fallback(value), nil
+	}
+	return "", fmt.Errorf("describe: not a property list")
+}
@@ -0,0 +1,143 @@
+// Package describe turns extended attribute values into human-readable
+// descriptions.
+//
+// Decoders are registered for attribute name patterns. Describe picks the
+// most recently registered decoder whose pattern matches the name and
+// falls back to a text, hex or base64 rendering when there is none or it
+// fails. The Default registry knows POSIX ACLs, file capabilities, SELinux
+// labels, NT security descriptors, common Apple attributes and the
+// GlusterFS and Lustre internal attributes.
+package describe
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strconv"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A Decoder describes the value of the attribute name. It returns an error
+// if the value can't be decoded, in which case the fallback is used.
+type Decoder func(name string, value []byte) (string, error)
+
+// Stringer adapts a decode function returning a fmt.Stringer, like
+// gluster.Decode and lustre.Decode, to a Decoder.
+func Stringer(decode func(name string, value []byte) (fmt.Stringer, error)) Decoder {
+	return func(name string, value []byte) (string, error) {
+		v, err := decode(name, value)
+		if err != nil {
+			return "", err
+		}
+		return v.String(), nil
+	}
+}
+
+type entry struct {
+	pattern string
+	decoder Decoder
+}
+
+// Registry maps attribute name patterns to decoders. It is safe for
+// concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	entries []entry
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return new(Registry)
+}
+
+// Register adds a decoder for attribute names matching pattern, which has
+// the syntax of path.Match. Decoders registered later take precedence.
+func (r *Registry) Register(pattern string, d Decoder) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("describe: invalid pattern %q: %v", pattern, err)
+	}
+
+	r.mu.Lock()
+	r.entries = append(r.entries, entry{pattern, d})
+	r.mu.Unlock()
+	return nil
+}
+
+// Lookup returns the decoder for the attribute name, or nil if there is
+// none.
+func (r *Registry) Lookup(name string) Decoder {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for i := len(r.entries) - 1; i >= 0; i-- {
+		if ok, _ := path.Match(r.entries[i].pattern, name); ok {
+			return r.entries[i].decoder
+		}
+	}
+	return nil
+}
+
+// Describe returns a human-readable description of value of the attribute
+// name.
+func (r *Registry) Describe(name string, value []byte) string {
+	if d := r.Lookup(name); d != nil {
+		if s, err := d(name, value); err == nil {
+			return s
+		}
+	}
+	return Fallback(value)
+}
+
+// Default is the registry used by Register and Describe.
+var Default = NewRegistry()
+
+// Register adds a decoder to the Default registry.
+func Register(pattern string, d Decoder) error {
+	return Default.Register(pattern, d)
+}
+
+// Describe describes value of the attribute name using the Default
+// registry.
+func Describe(name string, value []byte) string {
+	return Default.Describe(name, value)
+}
+
+// maxHexLen is the longest value Fallback renders in hex rather than
+// base64.
+const maxHexLen = 64
+
+// Fallback renders a value without knowledge of its format, using the
+// encodings of getfattr(1): printable text is quoted, short binary values
+// are shown in hex with a 0x prefix and longer ones in base64 with a 0s
+// prefix. A single trailing NUL, common in C strings, doesn't make a value
+// binary.
+func Fallback(value []byte) string {
+	if isText(value) {
+		if n := len(value); n > 0 && value[n-1] == 0 {
+			value = value[:n-1]
+		}
+		return strconv.Quote(string(value))
+	}
+	if len(value) <= maxHexLen {
+		return "0x" + hex.EncodeToString(value)
+	}
+	return "0s" + base64.StdEncoding.EncodeToString(value)
+}
+
+func isText(b []byte) bool {
+	if n := len(b); n > 0 && b[n-1] == 0 {
+		b = b[:n-1]
+	}
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && r != '\t' && r != '\n' {
+			return false
+		}
+	}
+	return true
+}
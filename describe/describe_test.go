@@ -0,0 +1,114 @@
+package describe
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFallback(t *testing.T) {
+	tests := []struct {
+		value    []byte
+		expected string
+	}{
+		{[]byte{}, `""`},
+		{[]byte("hello world"), `"hello world"`},
+		{[]byte("unconfined_u:object_r:user_home_t:s0\x00"), `"unconfined_u:object_r:user_home_t:s0"`},
+		{[]byte{0xde, 0xad, 0xbe, 0xef}, "0xdeadbeef"},
+		{make([]byte, 66), "0s" + strings.Repeat("A", 88)},
+	}
+
+	for _, test := range tests {
+		if got := Fallback(test.value); got != test.expected {
+			t.Errorf("Fallback(%q): got %s, expected %s", test.value, got, test.expected)
+		}
+	}
+}
+
+func TestDescribeBuiltins(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    []byte
+		expected string
+	}{
+		{
+			"system.posix_acl_access",
+			[]byte{2, 0, 0, 0, 1, 0, 6, 0, 0xff, 0xff, 0xff, 0xff, 4, 0, 4, 0, 0xff, 0xff, 0xff, 0xff, 0x20, 0, 0, 0, 0xff, 0xff, 0xff, 0xff},
+			"user::rw-,group::r--,other::---",
+		},
+		{
+			"security.capability",
+			[]byte{1, 0, 0, 2, 0, 0x04, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+			"cap_net_bind_service=ep",
+		},
+		{
+			"security.selinux",
+			[]byte("system_u:object_r:bin_t:s0\x00"),
+			"system_u:object_r:bin_t:s0",
+		},
+		{
+			"com.apple.quarantine",
+			[]byte("0083;5f3c1a2b;Safari;"),
+			`flags=0083 time=2020-08-18T18:12:59Z agent="Safari"`,
+		},
+		{
+			"com.apple.metadata:kMDItemWhereFroms",
+			append([]byte("bplist00"), make([]byte, 40)...),
+			"binary property list, 48 bytes",
+		},
+		{
+			"trusted.afr.dirty",
+			[]byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0},
+			"data=0 metadata=1 entry=0",
+		},
+		{
+			"security.capability",
+			[]byte{1, 2, 3},
+			"0x010203",
+		},
+	}
+
+	for _, test := range tests {
+		if got := Describe(test.name, test.value); got != test.expected {
+			t.Errorf("Describe(%q): got %q, expected %q", test.name, got, test.expected)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	upper := func(name string, value []byte) (string, error) {
+		return strings.ToUpper(string(value)), nil
+	}
+	failing := func(name string, value []byte) (string, error) {
+		return "", errors.New("failed")
+	}
+
+	if err := r.Register("user.[", upper); err == nil {
+		t.Error("Register(): expected error on invalid pattern")
+	}
+	r.Register("user.*", upper)
+
+	if got := r.Describe("user.mime_type", []byte("text/plain")); got != "TEXT/PLAIN" {
+		t.Errorf("Describe(): got %q", got)
+	}
+	if got := r.Describe("trusted.x", []byte("text/plain")); got != `"text/plain"` {
+		t.Errorf("Describe(): got %q", got)
+	}
+
+	r.Register("user.mime_type", failing)
+	if got := r.Describe("user.mime_type", []byte("text/plain")); got != `"text/plain"` {
+		t.Errorf("Describe(): got %q, expected fallback", got)
+	}
+}
+
+func TestDescribeFinderInfo(t *testing.T) {
+	value := make([]byte, 32)
+	copy(value, "TEXTttxt")
+	value[9] = 0x0c
+
+	expected := `type="TEXT" creator="ttxt" flags=0x000c color=red`
+	if got := Describe("com.apple.FinderInfo", value); got != expected {
+		t.Errorf("Describe(): got %q, expected %q", got, expected)
+	}
+}
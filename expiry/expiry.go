@@ -0,0 +1,276 @@
+// Package expiry stores extended attribute values that expire.
+//
+// An expiring value is the attribute value prefixed with a 12 byte header:
+// the magic "XEXP" followed by the expiry time as big-endian Unix
+// nanoseconds. Get treats expired values, which are left in place until
+// removed, as missing: its error satisfies xattr.IsNotExist. Sweep walks a
+// tree removing them from the attributes or namespaces it is given.
+package expiry
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ivaxer/go-xattr"
+	"github.com/ivaxer/go-xattr/internal/errno"
+)
+
+const (
+	magic      = "XEXP"
+	headerSize = len(magic) + 8
+)
+
+// ErrNotExpiring is returned by Get for values that are not in the
+// expiring format.
+var ErrNotExpiring = errors.New("expiry: value has no expiry header")
+
+// ErrNoSelection is returned by Sweep when neither names nor namespaces
+// are given.
+var ErrNoSelection = errors.New("expiry: no attributes or namespaces to sweep")
+
+// Expiry times UnixNano can represent.
+var (
+	minTime = time.Unix(0, math.MinInt64)
+	maxTime = time.Unix(0, math.MaxInt64)
+)
+
+// now is time.Now, replaced by tests.
+var now = time.Now
+
+// Encode returns value prefixed with a header recording the expiry time.
+// Times outside the years 1678 to 2262, including the zero time, are
+// clamped to that range.
+func Encode(value []byte, expires time.Time) []byte {
+	if expires.Before(minTime) {
+		expires = minTime
+	} else if expires.After(maxTime) {
+		expires = maxTime
+	}
+	b := make([]byte, headerSize+len(value))
+	copy(b, magic)
+	binary.BigEndian.PutUint64(b[len(magic):], uint64(expires.UnixNano()))
+	copy(b[headerSize:], value)
+	return b
+}
+
+// Decode splits an expiring value into the value and its expiry time. It
+// reports false if b is not an expiring value.
+func Decode(b []byte) (value []byte, expires time.Time, ok bool) {
+	if len(b) < headerSize || !bytes.HasPrefix(b, []byte(magic)) {
+		return nil, time.Time{}, false
+	}
+	ns := int64(binary.BigEndian.Uint64(b[len(magic):]))
+	return b[headerSize:], time.Unix(0, ns), true
+}
+
+// Set sets name on path to value, expiring after ttl.
+func Set(path, name string, value []byte, ttl time.Duration) error {
+	return SetUntil(path, name, value, now().Add(ttl))
+}
+
+// SetUntil sets name on path to value, expiring at expires.
+func SetUntil(path, name string, value []byte, expires time.Time) error {
+	return xattr.Set(path, name, Encode(value, expires))
+}
+
+// Get returns the value of name on path. If the value has expired, the
+// error satisfies xattr.IsNotExist as if the attribute wasn't there.
+// Values without the expiry header yield ErrNotExpiring.
+func Get(path, name string) ([]byte, error) {
+	value, _, err := GetExpiry(path, name)
+	return value, err
+}
+
+// GetExpiry is like Get and also returns the expiry time.
+func GetExpiry(path, name string) ([]byte, time.Time, error) {
+	b, err := xattr.Get(path, name)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	value, expires, ok := Decode(b)
+	if !ok {
+		return nil, time.Time{}, &os.PathError{Op: "getxattr", Path: path, Err: ErrNotExpiring}
+	}
+	if !now().Before(expires) {
+		return nil, time.Time{}, &os.PathError{Op: "getxattr", Path: path, Err: errno.NoAttr}
+	}
+	return value, expires, nil
+}
+
+// Expired reports whether b is an expiring value that has expired.
+func Expired(b []byte) bool {
+	_, expires, ok := Decode(b)
+	return ok && !now().Before(expires)
+}
+
+// SweepOptions configure Sweep.
+type SweepOptions struct {
+	// Names and Namespaces select the attributes to sweep: those named
+	// in Names and those starting with one of Namespaces, e.g. "user.".
+	// At least one must be given, as values of other applications may
+	// happen to start with the header.
+	Names      []string
+	Namespaces []string
+
+	// Rate limits the number of files examined per second. Zero means
+	// no limit.
+	Rate float64
+
+	// DryRun reports expired attributes without removing them.
+	DryRun bool
+
+	// Removed, if set, is called for each expired attribute.
+	Removed func(path, name string)
+}
+
+// SweepStats summarizes a sweep.
+type SweepStats struct {
+	Files   int
+	Removed int
+	Errors  int
+}
+
+// Sweep walks the tree at root and removes expired attributes. Symbolic
+// links are not followed. Files that can't be read are counted in Errors
+// and skipped; the first such error is returned after the walk.
+//
+// An expired value is read again just before it is removed and kept if it
+// changed, but a value set between that read and the removal is lost:
+// there is no atomic compare and remove.
+func Sweep(root string, opts *SweepOptions) (SweepStats, error) {
+	var o SweepOptions
+	if opts != nil {
+		o = *opts
+	}
+	if len(o.Names) == 0 && len(o.Namespaces) == 0 {
+		return SweepStats{}, ErrNoSelection
+	}
+
+	var interval time.Duration
+	if o.Rate > 0 {
+		interval = time.Duration(float64(time.Second) / o.Rate)
+	}
+	var next time.Time
+
+	var st SweepStats
+	var firstErr error
+	fail := func(err error) {
+		st.Errors++
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			fail(err)
+			return nil
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+
+		if interval > 0 {
+			if d := time.Until(next); d > 0 {
+				time.Sleep(d)
+			}
+			next = time.Now().Add(interval)
+		}
+		st.Files++
+
+		names, err := o.names(path)
+		if err != nil {
+			fail(err)
+			return nil
+		}
+		for _, name := range names {
+			b, err := xattr.Get(path, name)
+			if err != nil {
+				if !xattr.IsNotExist(err) {
+					fail(err)
+				}
+				continue
+			}
+			if !Expired(b) {
+				continue
+			}
+			if !o.DryRun {
+				removed, err := removeIfEqual(path, name, b)
+				if err != nil {
+					fail(err)
+					continue
+				}
+				if !removed {
+					continue
+				}
+			}
+			st.Removed++
+			if o.Removed != nil {
+				o.Removed(path, name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return st, err
+	}
+	return st, firstErr
+}
+
+// names returns the attributes of path selected by o.
+func (o *SweepOptions) names(path string) ([]string, error) {
+	if len(o.Namespaces) == 0 {
+		return o.Names, nil
+	}
+	all, err := xattr.List(path)
+	if err != nil {
+		return nil, err
+	}
+	names := append([]string(nil), o.Names...)
+	for _, name := range all {
+		for _, ns := range o.Namespaces {
+			if strings.HasPrefix(name, ns) && !contains(o.Names, name) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	return names, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// removeIfEqual removes name from path if its value is still expected. It
+// reports whether the attribute was removed.
+func removeIfEqual(path, name string, expected []byte) (bool, error) {
+	b, err := xattr.Get(path, name)
+	if err != nil {
+		if xattr.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !bytes.Equal(b, expected) {
+		return false, nil
+	}
+	if err := xattr.Remove(path, name); err != nil {
+		if xattr.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,108 @@
+package expiry
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ivaxer/go-xattr"
+	"github.com/ivaxer/go-xattr/xattrtest"
+)
+
+func TestGet(t *testing.T) {
+	path := xattrtest.TempFile(t)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	defer func() { now = time.Now }()
+	now = func() time.Time { return base }
+
+	if err := Set(path, "user.hint", []byte("warm"), time.Hour); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	value, expires, err := GetExpiry(path, "user.hint")
+	if err != nil || string(value) != "warm" || !expires.Equal(base.Add(time.Hour)) {
+		t.Errorf("GetExpiry(): got %q, %v, %v", value, expires, err)
+	}
+
+	now = func() time.Time { return base.Add(time.Hour) }
+	if _, err := Get(path, "user.hint"); !xattr.IsNotExist(err) {
+		t.Errorf("Get(): got %v, expected not exist after expiry", err)
+	}
+
+	xattr.Set(path, "user.plain", []byte("value"))
+	if _, err := Get(path, "user.plain"); err == nil || xattr.IsNotExist(err) {
+		t.Errorf("Get(): got %v, expected ErrNotExpiring", err)
+	}
+	if _, _, ok := Decode([]byte("XEX")); ok {
+		t.Error("Decode(): accepted a short value")
+	}
+	if _, expires, _ := Decode(Encode(nil, time.Time{})); !expires.Equal(minTime) {
+		t.Errorf("Encode(zero time): got expiry %v, expected %v", expires, minTime)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	dir := xattrtest.TempDir(t)
+
+	base := time.Now()
+	var files []string
+	for _, name := range []string{"a", "b", "c"} {
+		path := filepath.Join(dir, name)
+		ioutil.WriteFile(path, nil, 0644)
+		files = append(files, path)
+	}
+	SetUntil(files[0], "user.old", []byte("x"), base.Add(-time.Minute))
+	SetUntil(files[0], "user.new", []byte("x"), base.Add(time.Hour))
+	SetUntil(files[1], "user.old", []byte("x"), base.Add(-time.Second))
+	xattr.Set(files[2], "user.plain", []byte("x"))
+	SetUntil(dir, "user.old", []byte("x"), base.Add(-time.Hour))
+
+	if _, err := Sweep(dir, nil); err != ErrNoSelection {
+		t.Errorf("Sweep(no selection): got %v, expected ErrNoSelection", err)
+	}
+
+	dry, err := Sweep(dir, &SweepOptions{Namespaces: []string{"user."}, DryRun: true})
+	if err != nil || dry.Removed != 3 || dry.Files != 4 {
+		t.Errorf("Sweep(dry run): got %+v, %v", dry, err)
+	}
+
+	var removed []string
+	start := time.Now()
+	st, err := Sweep(dir, &SweepOptions{Names: []string{"user.old"}, Rate: 100, Removed: func(path, name string) {
+		removed = append(removed, filepath.Base(path)+":"+name)
+	}})
+	if err != nil {
+		t.Fatalf("Sweep() failed: %v", err)
+	}
+	if st.Removed != 3 || len(removed) != 3 {
+		t.Errorf("Sweep(): got %+v, removed %v", st, removed)
+	}
+	if d := time.Since(start); d < 30*time.Millisecond {
+		t.Errorf("Sweep(): took %v, expected rate limiting", d)
+	}
+
+	names, _ := xattr.List(files[0])
+	if len(names) != 1 || names[0] != "user.new" {
+		t.Errorf("Sweep(): left %v", names)
+	}
+	if names, _ := xattr.List(files[2]); len(names) != 1 {
+		t.Errorf("Sweep(): touched plain attribute, left %v", names)
+	}
+}
+
+func TestRemoveIfEqual(t *testing.T) {
+	path := xattrtest.TempFile(t)
+
+	old := Encode([]byte("x"), time.Now().Add(-time.Minute))
+	xattr.Set(path, "user.hint", old)
+
+	// The value was refreshed after the sweep read it.
+	SetUntil(path, "user.hint", []byte("x"), time.Now().Add(time.Hour))
+	if removed, err := removeIfEqual(path, "user.hint", old); removed || err != nil {
+		t.Errorf("removeIfEqual(refreshed): got %v, %v", removed, err)
+	}
+	if _, err := Get(path, "user.hint"); err != nil {
+		t.Errorf("Get(): refreshed value lost: %v", err)
+	}
+}
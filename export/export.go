@@ -0,0 +1,300 @@
+// Package export writes the extended attributes of a directory tree as
+// CSV or TSV for spreadsheets and ad hoc analysis.
+//
+// Write emits one row per attribute:
+//
+//	path,name,size,value
+//	docs/a.txt,user.mime_type,10,text/plain
+//
+// and WriteAggregate one row per attribute name, counting the files that
+// carry it:
+//
+//	name,files,total_size,max_size
+//	user.mime_type,1520,15200,24
+//
+// Paths are relative to the root, with '/' separators. Symbolic links are
+// not followed. Files whose attributes can't be read are skipped; the
+// first such error is returned once the rest of the tree is written.
+//
+// Paths, names and Text values starting with '=', '+', '-' or '@' are
+// prefixed with a single quote, so spreadsheets opening the output don't
+// evaluate them as formulas.
+package export
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ivaxer/go-xattr"
+)
+
+// Encoding selects how values are written.
+type Encoding string
+
+// Encodings.
+const (
+	// Text writes printable UTF-8 values as is and others in base64
+	// with a "0s" prefix, as getfattr does.
+	Text   Encoding = "text"
+	Hex    Encoding = "hex"
+	Base64 Encoding = "base64"
+	// SHA256 writes the hex encoded digest of the value.
+	SHA256 Encoding = "sha256"
+	// None leaves the value column empty.
+	None Encoding = "none"
+)
+
+// ParseEncoding returns the encoding named s.
+func ParseEncoding(s string) (Encoding, error) {
+	switch e := Encoding(s); e {
+	case Text, Hex, Base64, SHA256, None:
+		return e, nil
+	}
+	return "", fmt.Errorf("export: unknown encoding %q", s)
+}
+
+// Options configure the export. A nil *Options writes CSV with Text
+// values.
+type Options struct {
+	// TSV selects tab separated output.
+	TSV bool
+
+	// Encoding of values. Empty means Text.
+	Encoding Encoding
+
+	// MaxValueLen truncates encoded values longer than this many bytes,
+	// marking them with a trailing "...". Zero means no limit.
+	MaxValueLen int
+
+	// Skipped, if set, is called for each file skipped because its
+	// attributes can't be read.
+	Skipped func(path string, err error)
+}
+
+func (o *Options) withDefaults() Options {
+	var opts Options
+	if o != nil {
+		opts = *o
+	}
+	if opts.Encoding == "" {
+		opts.Encoding = Text
+	}
+	return opts
+}
+
+func (o *Options) writer(w io.Writer) *csv.Writer {
+	cw := csv.NewWriter(w)
+	if o.TSV {
+		cw.Comma = '\t'
+	}
+	return cw
+}
+
+// encode returns the value as written in the value column.
+func (o *Options) encode(v []byte) string {
+	var s string
+	switch o.Encoding {
+	case Hex:
+		s = hex.EncodeToString(v)
+	case Base64:
+		s = base64.StdEncoding.EncodeToString(v)
+	case SHA256:
+		sum := sha256.Sum256(v)
+		return hex.EncodeToString(sum[:])
+	case None:
+		return ""
+	default:
+		if isText(v) {
+			s = escapeFormula(string(v))
+		} else {
+			s = "0s" + base64.StdEncoding.EncodeToString(v)
+		}
+	}
+
+	if o.MaxValueLen > 0 && len(s) > o.MaxValueLen {
+		n := o.MaxValueLen
+		for n > 0 && !utf8.RuneStart(s[n]) {
+			n--
+		}
+		s = s[:n] + "..."
+	}
+	return s
+}
+
+// escapeFormula prefixes s with a single quote if a spreadsheet would take
+// it for a formula.
+func escapeFormula(s string) string {
+	if s != "" && strings.IndexByte("=+-@", s[0]) >= 0 {
+		return "'" + s
+	}
+	return s
+}
+
+func isText(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if r < ' ' && r != '\t' || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// walk calls fn with the attributes of each file in the tree at root.
+// Files that can't be read are skipped and reported to o.Skipped; the
+// first such error is returned after the walk.
+func walk(root string, o *Options, fn func(rel string, names []string, values [][]byte) error) error {
+	var firstErr error
+	skip := func(path string, err error) {
+		if o.Skipped != nil {
+			o.Skipped(path, err)
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			skip(path, err)
+			return nil
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		names, err := xattr.List(path)
+		if err != nil {
+			skip(path, err)
+			return nil
+		}
+		sort.Strings(names)
+
+		var found []string
+		var values [][]byte
+		for _, name := range names {
+			v, err := xattr.Get(path, name)
+			if err != nil {
+				if xattr.IsNotExist(err) {
+					continue
+				}
+				skip(path, err)
+				return nil
+			}
+			found = append(found, name)
+			values = append(values, v)
+		}
+		return fn(filepath.ToSlash(rel), found, values)
+	})
+	if err != nil {
+		return err
+	}
+	return firstErr
+}
+
+// Write writes a row for each attribute in the tree at root.
+func Write(w io.Writer, root string, opts *Options) error {
+	o := opts.withDefaults()
+	cw := o.writer(w)
+	if err := cw.Write([]string{"path", "name", "size", "value"}); err != nil {
+		return err
+	}
+
+	err := walk(root, &o, func(rel string, names []string, values [][]byte) error {
+		for i, name := range names {
+			row := []string{escapeFormula(rel), escapeFormula(name), strconv.Itoa(len(values[i])), o.encode(values[i])}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	cw.Flush()
+	if cerr := cw.Error(); cerr != nil {
+		return cerr
+	}
+	return err
+}
+
+// Stat summarizes the occurrences of an attribute name.
+type Stat struct {
+	Name      string
+	Files     int
+	TotalSize int64
+	MaxSize   int
+}
+
+// Aggregate returns the statistics of the attribute names in the tree at
+// root, sorted by name. Only the Skipped field of opts is used. If files
+// were skipped, the statistics of the others are returned with the first
+// error.
+func Aggregate(root string, opts *Options) ([]Stat, error) {
+	o := opts.withDefaults()
+	stats := make(map[string]*Stat)
+	err := walk(root, &o, func(rel string, names []string, values [][]byte) error {
+		for i, name := range names {
+			st := stats[name]
+			if st == nil {
+				st = &Stat{Name: name}
+				stats[name] = st
+			}
+			st.Files++
+			st.TotalSize += int64(len(values[i]))
+			if len(values[i]) > st.MaxSize {
+				st.MaxSize = len(values[i])
+			}
+		}
+		return nil
+	})
+
+	list := make([]Stat, 0, len(stats))
+	for _, st := range stats {
+		list = append(list, *st)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, err
+}
+
+// WriteAggregate writes a row of statistics for each attribute name in the
+// tree at root. Only the TSV and Skipped fields of opts are used.
+func WriteAggregate(w io.Writer, root string, opts *Options) error {
+	stats, walkErr := Aggregate(root, opts)
+
+	o := opts.withDefaults()
+	cw := o.writer(w)
+	if err := cw.Write([]string{"name", "files", "total_size", "max_size"}); err != nil {
+		return err
+	}
+	for _, st := range stats {
+		row := []string{
+			escapeFormula(st.Name),
+			strconv.Itoa(st.Files),
+			strconv.FormatInt(st.TotalSize, 10),
+			strconv.Itoa(st.MaxSize),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	return walkErr
+}
@@ -0,0 +1,144 @@
+package export
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ivaxer/go-xattr"
+	"github.com/ivaxer/go-xattr/xattrtest"
+)
+
+func mktree(t *testing.T) string {
+	dir := xattrtest.TempDir(t)
+	os.Mkdir(filepath.Join(dir, "docs"), 0755)
+	for _, name := range []string{"docs/a.txt", "b.bin"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatalf("WriteFile() failed: %v", err)
+		}
+	}
+
+	set := func(name, attr, value string) {
+		if err := xattr.Set(filepath.Join(dir, name), attr, []byte(value)); err != nil {
+			t.Fatalf("Set() failed: %v", err)
+		}
+	}
+	set("docs/a.txt", "user.mime_type", "text/plain")
+	set("docs/a.txt", "user.note", "a, \"quoted\" note")
+	set("b.bin", "user.mime_type", "application/octet-stream")
+	set("b.bin", "user.raw", "\x00\x01\x02")
+	return dir
+}
+
+func TestWrite(t *testing.T) {
+	dir := mktree(t)
+
+	tests := []struct {
+		opts     *Options
+		expected string
+	}{
+		{nil, `path,name,size,value
+b.bin,user.mime_type,24,application/octet-stream
+b.bin,user.raw,3,0sAAEC
+docs/a.txt,user.mime_type,10,text/plain
+docs/a.txt,user.note,16,"a, ""quoted"" note"
+`},
+		{&Options{TSV: true, Encoding: Hex, MaxValueLen: 8}, "path\tname\tsize\tvalue\n" +
+			"b.bin\tuser.mime_type\t24\t6170706c...\n" +
+			"b.bin\tuser.raw\t3\t000102\n" +
+			"docs/a.txt\tuser.mime_type\t10\t74657874...\n" +
+			"docs/a.txt\tuser.note\t16\t612c2022...\n"},
+	}
+	for i, test := range tests {
+		var buf bytes.Buffer
+		if err := Write(&buf, dir, test.opts); err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+		if buf.String() != test.expected {
+			t.Errorf("Write(%d): got\n%s\nexpected\n%s", i, buf.String(), test.expected)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, dir, &Options{Encoding: SHA256}); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	// sha256("text/plain")
+	if !strings.Contains(buf.String(), "docs/a.txt,user.mime_type,10,dc23933049d8b06808e15916d9cc735bd5c82fc87e5f3a970442f6fc04f5a275\n") {
+		t.Errorf("Write(sha256): got\n%s", buf.String())
+	}
+
+	if _, err := ParseEncoding("rot13"); err == nil {
+		t.Error("ParseEncoding(): expected error")
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	dir := mktree(t)
+
+	var buf bytes.Buffer
+	if err := WriteAggregate(&buf, dir, nil); err != nil {
+		t.Fatalf("WriteAggregate() failed: %v", err)
+	}
+	expected := `name,files,total_size,max_size
+user.mime_type,2,34,24
+user.note,1,16,16
+user.raw,1,3,3
+`
+	if buf.String() != expected {
+		t.Errorf("WriteAggregate(): got\n%s\nexpected\n%s", buf.String(), expected)
+	}
+}
+
+func TestFormula(t *testing.T) {
+	dir := xattrtest.TempDir(t)
+	name := filepath.Join(dir, "=cmd")
+	if err := ioutil.WriteFile(name, nil, 0644); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+	xattr.Set(name, "user.formula", []byte("=HYPERLINK(\"http://example.com\")"))
+	xattr.Set(name, "user.number", []byte("-1"))
+
+	var buf bytes.Buffer
+	if err := Write(&buf, dir, nil); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	expected := `path,name,size,value
+'=cmd,user.formula,32,"'=HYPERLINK(""http://example.com"")"
+'=cmd,user.number,2,'-1
+`
+	if buf.String() != expected {
+		t.Errorf("Write(): got\n%s\nexpected\n%s", buf.String(), expected)
+	}
+}
+
+func TestSkipped(t *testing.T) {
+	dir := mktree(t)
+	gone := filepath.Join(dir, "c")
+	ioutil.WriteFile(gone, nil, 0644)
+
+	// c is listed with the root, then removed before it is read.
+	var skipped []string
+	o := &Options{Skipped: func(path string, err error) { skipped = append(skipped, path) }}
+	files := 0
+	err := walk(dir, o, func(rel string, names []string, values [][]byte) error {
+		if rel == "b.bin" {
+			os.Remove(gone)
+		}
+		files++
+		return nil
+	})
+	if !os.IsNotExist(err) || len(skipped) != 1 || skipped[0] != gone {
+		t.Errorf("walk(): got %v, skipped %v", err, skipped)
+	}
+	if files != 4 {
+		t.Errorf("walk(): visited %d files, expected 4", files)
+	}
+
+	if _, err := Aggregate(filepath.Join(dir, "missing"), nil); !os.IsNotExist(err) {
+		t.Errorf("Aggregate(): got %v, expected not exist", err)
+	}
+}
@@ -0,0 +1,157 @@
+package ext4
+
+import (
+	"encoding/binary"
+)
+
+const (
+	iBlockOffset = 0x28
+	iBlockSize   = 60
+
+	extentMagic      = 0xf30a
+	extentHeaderSize = 12
+	extentEntrySize  = 12
+	extentMaxInit    = 32768
+
+	directBlocks = 12
+)
+
+func inodeFlags(inode []byte) uint32 {
+	return binary.LittleEndian.Uint32(inode[0x20:])
+}
+
+func inodeSize(inode []byte) uint64 {
+	return uint64(binary.LittleEndian.Uint32(inode[0x04:])) |
+		uint64(binary.LittleEndian.Uint32(inode[0x6c:]))<<32
+}
+
+// readData returns the contents of the file described by inode.
+func (img *Image) readData(inode []byte) ([]byte, error) {
+	size := inodeSize(inode)
+	if size > maxDataSize {
+		return nil, ErrCorrupt
+	}
+	buf := make([]byte, size)
+	iblock := inode[iBlockOffset : iBlockOffset+iBlockSize]
+
+	flags := inodeFlags(inode)
+	switch {
+	case flags&inodeFlagInlineData != 0:
+		n := copy(buf, iblock)
+		if uint64(n) < size {
+			data, err := img.inlineData(inode)
+			if err != nil {
+				return nil, err
+			}
+			copy(buf[n:], data)
+		}
+	case flags&inodeFlagExtents != 0:
+		if err := img.readExtents(buf, iblock, 0); err != nil {
+			return nil, err
+		}
+	default:
+		lblk := uint64(0)
+		for i := 0; i < directBlocks+3; i++ {
+			blk := uint64(binary.LittleEndian.Uint32(iblock[4*i:]))
+			level := 0
+			if i >= directBlocks {
+				level = i - directBlocks + 1
+			}
+			if err := img.readMapped(buf, blk, level, &lblk); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return buf, nil
+}
+
+// readExtents copies the data of the extent tree node into buf.
+func (img *Image) readExtents(buf, node []byte, depth int) error {
+	if len(node) < extentHeaderSize || binary.LittleEndian.Uint16(node) != extentMagic || depth > 5 {
+		return ErrCorrupt
+	}
+	entries := int(binary.LittleEndian.Uint16(node[2:]))
+	leaf := binary.LittleEndian.Uint16(node[6:]) == 0
+	if extentHeaderSize+entries*extentEntrySize > len(node) {
+		return ErrCorrupt
+	}
+
+	for i := 0; i < entries; i++ {
+		e := node[extentHeaderSize+i*extentEntrySize:]
+		if !leaf {
+			child := uint64(binary.LittleEndian.Uint32(e[4:])) |
+				uint64(binary.LittleEndian.Uint16(e[8:]))<<32
+			b, err := img.readBlock(child)
+			if err != nil {
+				return err
+			}
+			if err := img.readExtents(buf, b, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+
+		lblk := uint64(binary.LittleEndian.Uint32(e[0:]))
+		n := uint64(binary.LittleEndian.Uint16(e[4:]))
+		if n > extentMaxInit {
+			// Uninitialized extents read as zeros.
+			continue
+		}
+		start := uint64(binary.LittleEndian.Uint16(e[6:]))<<32 |
+			uint64(binary.LittleEndian.Uint32(e[8:]))
+		if err := img.copyBlocks(buf, lblk, start, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readMapped copies the data of the block map rooted at blk, an indirect
+// block of the given level or a data block at level zero, into buf.
+// lblk tracks the logical block number and advances even over holes.
+func (img *Image) readMapped(buf []byte, blk uint64, level int, lblk *uint64) error {
+	bs := uint64(img.blockSize)
+	span := uint64(1)
+	for i := 0; i < level; i++ {
+		span *= bs / 4
+	}
+
+	if *lblk*bs >= uint64(len(buf)) {
+		return nil
+	}
+	if blk == 0 {
+		*lblk += span
+		return nil
+	}
+	if level == 0 {
+		err := img.copyBlocks(buf, *lblk, blk, 1)
+		*lblk++
+		return err
+	}
+
+	b, err := img.readBlock(blk)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(b); i += 4 {
+		if err := img.readMapped(buf, uint64(binary.LittleEndian.Uint32(b[i:])), level-1, lblk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyBlocks copies n blocks starting at physical block start to the
+// logical block lblk of buf, truncated to the length of buf.
+func (img *Image) copyBlocks(buf []byte, lblk, start, n uint64) error {
+	bs := uint64(img.blockSize)
+	off := lblk * bs
+	if off >= uint64(len(buf)) {
+		return nil
+	}
+	end := off + n*bs
+	if end > uint64(len(buf)) {
+		end = uint64(len(buf))
+	}
+	return img.readAt(buf[off:end], int64(start*bs))
+}
@@ -0,0 +1,272 @@
+// Package ext4 reads extended attributes from ext2, ext3 and ext4
+// filesystem images without mounting them.
+//
+// Image has the List and Get methods of the xattr package, taking paths
+// inside the image. It parses the superblock, group descriptors, in the
+// classic or the meta_bg layout, and inode tables, and reads attributes
+// from the in-inode area, from external attribute blocks and from EA
+// inodes. Errors mimic those of the system calls, so os.IsNotExist and
+// xattr.IsNotExist work on them.
+//
+// The reader is read-only and doesn't replay the journal; images of
+// filesystems that weren't cleanly unmounted may show stale data.
+package ext4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+)
+
+const (
+	superblockOffset = 1024
+	superblockSize   = 1024
+	superMagic       = 0xef53
+	rootInode        = 2
+	goodOldInodeSize = 128
+
+	compatSparseSuper2  = 0x200
+	roCompatSparseSuper = 0x1
+	incompatMetaBG      = 0x10
+	incompat64bit       = 0x80
+
+	inodeFlagExtents    = 0x80000
+	inodeFlagInlineData = 0x10000000
+
+	modeTypeMask = 0xf000
+	modeDir      = 0x4000
+
+	// maxDataSize bounds the files read by the image, which are
+	// directories and EA inodes, to guard against corrupt sizes.
+	maxDataSize = 1 << 30
+)
+
+// ErrCorrupt is returned when the image contains inconsistent metadata.
+var ErrCorrupt = errors.New("ext4: corrupt filesystem image")
+
+// Image is an ext2/3/4 filesystem image.
+type Image struct {
+	r io.ReaderAt
+	c io.Closer
+
+	blockSize      int64
+	inodeSize      int64
+	inodesPerGroup uint32
+	inodesCount    uint32
+	blocksPerGroup uint64
+	firstDataBlock uint64
+	descSize       int64
+	compat         uint32
+	roCompat       uint32
+	incompat       uint32
+	firstMetaBG    uint64
+	backupBGs      [2]uint64
+}
+
+// Open opens the image in the named file or block device.
+func Open(name string) (*Image, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	img, err := New(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	img.c = f
+	return img, nil
+}
+
+// New reads the image from r.
+func New(r io.ReaderAt) (*Image, error) {
+	sb := make([]byte, superblockSize)
+	if _, err := r.ReadAt(sb, superblockOffset); err != nil {
+		return nil, fmt.Errorf("ext4: reading superblock: %v", err)
+	}
+	if binary.LittleEndian.Uint16(sb[0x38:]) != superMagic {
+		return nil, errors.New("ext4: not an ext2/3/4 filesystem")
+	}
+
+	logBlockSize := binary.LittleEndian.Uint32(sb[0x18:])
+	if logBlockSize > 6 {
+		return nil, ErrCorrupt
+	}
+
+	img := &Image{
+		r:              r,
+		blockSize:      1024 << logBlockSize,
+		inodeSize:      goodOldInodeSize,
+		inodesCount:    binary.LittleEndian.Uint32(sb[0x00:]),
+		firstDataBlock: uint64(binary.LittleEndian.Uint32(sb[0x14:])),
+		blocksPerGroup: uint64(binary.LittleEndian.Uint32(sb[0x20:])),
+		inodesPerGroup: binary.LittleEndian.Uint32(sb[0x28:]),
+		compat:         binary.LittleEndian.Uint32(sb[0x5c:]),
+		roCompat:       binary.LittleEndian.Uint32(sb[0x64:]),
+		incompat:       binary.LittleEndian.Uint32(sb[0x60:]),
+		firstMetaBG:    uint64(binary.LittleEndian.Uint32(sb[0x104:])),
+		backupBGs: [2]uint64{
+			uint64(binary.LittleEndian.Uint32(sb[0x24c:])),
+			uint64(binary.LittleEndian.Uint32(sb[0x250:])),
+		},
+		descSize: 32,
+	}
+	if binary.LittleEndian.Uint32(sb[0x4c:]) >= 1 {
+		img.inodeSize = int64(binary.LittleEndian.Uint16(sb[0x58:]))
+	}
+	if img.incompat&incompat64bit != 0 {
+		if n := int64(binary.LittleEndian.Uint16(sb[0xfe:])); n > 32 {
+			img.descSize = n
+		}
+	}
+	if img.inodesPerGroup == 0 || img.blocksPerGroup == 0 ||
+		img.inodeSize < goodOldInodeSize || img.inodeSize > img.blockSize || img.descSize > img.blockSize {
+		return nil, ErrCorrupt
+	}
+	return img, nil
+}
+
+// Close closes the file opened by Open.
+func (img *Image) Close() error {
+	if img.c == nil {
+		return nil
+	}
+	return img.c.Close()
+}
+
+func (img *Image) readAt(b []byte, off int64) error {
+	if _, err := img.r.ReadAt(b, off); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrCorrupt
+		}
+		return err
+	}
+	return nil
+}
+
+func (img *Image) readBlock(blk uint64) ([]byte, error) {
+	b := make([]byte, img.blockSize)
+	return b, img.readAt(b, int64(blk)*img.blockSize)
+}
+
+// readInode returns the raw on-disk inode ino.
+func (img *Image) readInode(ino uint32) ([]byte, error) {
+	if ino == 0 || ino > img.inodesCount {
+		return nil, ErrCorrupt
+	}
+	group := uint64((ino - 1) / img.inodesPerGroup)
+	index := int64((ino - 1) % img.inodesPerGroup)
+
+	desc := make([]byte, img.descSize)
+	if err := img.readAt(desc, img.descOffset(group)); err != nil {
+		return nil, err
+	}
+	table := uint64(binary.LittleEndian.Uint32(desc[0x08:]))
+	if img.descSize >= 64 {
+		table |= uint64(binary.LittleEndian.Uint32(desc[0x28:])) << 32
+	}
+
+	b := make([]byte, img.inodeSize)
+	return b, img.readAt(b, int64(table)*img.blockSize+index*img.inodeSize)
+}
+
+// descOffset returns the offset of the descriptor of group. The
+// descriptors follow the superblock of group 0, except with meta_bg, where
+// from the meta group firstMetaBG on each block of descriptors is stored
+// in the first group it describes.
+func (img *Image) descOffset(group uint64) int64 {
+	perBlock := uint64(img.blockSize / img.descSize)
+	metaGroup := group / perBlock
+	if img.incompat&incompatMetaBG == 0 || metaGroup < img.firstMetaBG {
+		return int64(img.firstDataBlock+1)*img.blockSize + int64(group)*img.descSize
+	}
+
+	first := metaGroup * perBlock
+	blk := img.firstDataBlock + first*img.blocksPerGroup
+	if img.hasSuper(first) {
+		blk++
+	}
+	return int64(blk)*img.blockSize + int64(group%perBlock)*img.descSize
+}
+
+// hasSuper reports whether group holds a superblock backup, as
+// ext4_bg_has_super does.
+func (img *Image) hasSuper(group uint64) bool {
+	switch {
+	case group == 0:
+		return true
+	case img.compat&compatSparseSuper2 != 0:
+		return group == img.backupBGs[0] || group == img.backupBGs[1]
+	case group <= 1 || img.roCompat&roCompatSparseSuper == 0:
+		return true
+	case group&1 == 0:
+		return false
+	}
+	return isPower(group, 3) || isPower(group, 5) || isPower(group, 7)
+}
+
+func isPower(n, base uint64) bool {
+	for n%base == 0 {
+		n /= base
+	}
+	return n == 1
+}
+
+// lookup returns the inode number of the file at name. Symbolic links are
+// not followed.
+func (img *Image) lookup(name string) (uint32, error) {
+	ino := uint32(rootInode)
+	for _, elem := range strings.Split(path.Clean("/"+name), "/") {
+		if elem == "" {
+			continue
+		}
+
+		inode, err := img.readInode(ino)
+		if err != nil {
+			return 0, err
+		}
+		if binary.LittleEndian.Uint16(inode)&modeTypeMask != modeDir {
+			return 0, syscall.ENOTDIR
+		}
+		data, err := img.readData(inode)
+		if err != nil {
+			return 0, err
+		}
+		if inodeFlags(inode)&inodeFlagInlineData != 0 && len(data) >= 4 {
+			// Inline directories start with the parent inode number.
+			data = data[4:]
+		}
+		if ino = findEntry(data, img.blockSize, elem); ino == 0 {
+			return 0, syscall.ENOENT
+		}
+	}
+	return ino, nil
+}
+
+// findEntry looks name up in the directory data and returns its inode
+// number, or zero if it isn't there. Hash tree directories keep their
+// index in entries covering whole blocks, so a linear scan of the leaf
+// blocks finds every name.
+func findEntry(data []byte, blockSize int64, name string) uint32 {
+	for off := 0; off+8 <= len(data); {
+		ino := binary.LittleEndian.Uint32(data[off:])
+		recLen := int(binary.LittleEndian.Uint16(data[off+4:]))
+		nameLen := int(data[off+6])
+		if recLen == 0 || recLen == 65535 {
+			recLen = int(blockSize)
+		}
+		if recLen < 8 || off+recLen > len(data) {
+			return 0
+		}
+		if ino != 0 && nameLen <= recLen-8 && string(data[off+8:off+8+nameLen]) == name {
+			return ino
+		}
+		off += recLen
+	}
+	return 0
+}
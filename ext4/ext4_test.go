@@ -0,0 +1,197 @@
+package ext4
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/ivaxer/go-xattr"
+	"github.com/ivaxer/go-xattr/internal/posixacl"
+)
+
+var testAttrs = []struct {
+	path, attr string
+	value      []byte
+}{
+	{"file", "user.small", []byte("in inode")},
+	{"file", "user.block", bytes.Repeat([]byte("b"), 1000)},
+	{"file", "user.ea_inode", bytes.Repeat([]byte("i"), 4096)},
+	{"file", posixacl.AccessAttr, posixacl.ACL{
+		{Tag: posixacl.UserObj, Perm: posixacl.Read | posixacl.Write},
+		{Tag: posixacl.User, Perm: posixacl.Read, ID: 1000},
+		{Tag: posixacl.GroupObj, Perm: posixacl.Read},
+		{Tag: posixacl.Mask, Perm: posixacl.Read},
+		{Tag: posixacl.Other},
+	}.Marshal()},
+	{"dir/nested", "user.nested", []byte("nested value")},
+	{"dir", "user.dir", []byte{0, 1, 2, 3}},
+}
+
+// fillers is the number of empty files in dir.
+const fillers = 200
+
+// mkimage builds an ext4 image of size with mkfs.ext4 and its options, and
+// sets testAttrs in it with debugfs, which doesn't depend on xattr support
+// of the host filesystem.
+func mkimage(t *testing.T, size string, options ...string) (string, func()) {
+	mkfs, err := exec.LookPath("mkfs.ext4")
+	if err != nil {
+		t.Skip("mkfs.ext4 not found")
+	}
+	debugfs, err := exec.LookPath("debugfs")
+	if err != nil {
+		t.Skip("debugfs not found")
+	}
+
+	dir, err := ioutil.TempDir("", "test_ext4_")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %v", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	src := filepath.Join(dir, "src")
+	if err := os.MkdirAll(filepath.Join(src, "dir"), 0755); err != nil {
+		t.Fatalf("MkdirAll() failed: %v", err)
+	}
+	for _, name := range []string{"file", "dir/nested"} {
+		if err := ioutil.WriteFile(filepath.Join(src, name), []byte("data"), 0644); err != nil {
+			t.Fatalf("WriteFile() failed: %v", err)
+		}
+	}
+	// Enough entries to spread the directory over several blocks.
+	for i := 0; i < fillers; i++ {
+		name := fmt.Sprintf("filler-with-a-rather-long-name-%03d", i)
+		if err := ioutil.WriteFile(filepath.Join(src, "dir", name), nil, 0644); err != nil {
+			t.Fatalf("WriteFile() failed: %v", err)
+		}
+	}
+
+	img := filepath.Join(dir, "fs.img")
+	args := append([]string{"-q", "-F"}, options...)
+	out, err := exec.Command(mkfs, append(args, "-d", src, img, size)...).CombinedOutput()
+	if err != nil {
+		cleanup()
+		t.Fatalf("mkfs.ext4 failed: %v: %s", err, out)
+	}
+
+	for i, a := range testAttrs {
+		value := filepath.Join(dir, fmt.Sprintf("value%d", i))
+		if err := ioutil.WriteFile(value, a.value, 0644); err != nil {
+			t.Fatalf("WriteFile() failed: %v", err)
+		}
+		cmd := fmt.Sprintf("ea_set -f %s /%s %s", value, a.path, a.attr)
+		out, err := exec.Command(debugfs, "-w", "-R", cmd, img).CombinedOutput()
+		if err != nil {
+			cleanup()
+			t.Fatalf("debugfs %s failed: %v: %s", cmd, err, out)
+		}
+	}
+	return img, cleanup
+}
+
+func TestImage(t *testing.T) {
+	name, cleanup := mkimage(t, "8M", "-b", "4096", "-O", "ea_inode")
+	defer cleanup()
+
+	img, err := Open(name)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer img.Close()
+
+	for _, a := range testAttrs {
+		got, err := img.Get(a.path, a.attr)
+		if err != nil {
+			t.Errorf("Get(%q, %q) failed: %v", a.path, a.attr, err)
+			continue
+		}
+		if !bytes.Equal(got, a.value) {
+			t.Errorf("Get(%q, %q): got %d bytes, expected %d", a.path, a.attr, len(got), len(a.value))
+		}
+	}
+
+	names, err := img.List("/file")
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	sort.Strings(names)
+	expected := []string{"system.posix_acl_access", "user.block", "user.ea_inode", "user.small"}
+	if len(names) != len(expected) {
+		t.Fatalf("List(): got %v, expected %v", names, expected)
+	}
+	for i := range names {
+		if names[i] != expected[i] {
+			t.Errorf("List(): got %v, expected %v", names, expected)
+		}
+	}
+
+	if _, err := img.Get("file", "user.missing"); !xattr.IsNotExist(err) {
+		t.Errorf("Get(): unexpected error value: %v", err)
+	}
+	if _, err := img.List("missing"); !os.IsNotExist(err) {
+		t.Errorf("List(): unexpected error value: %v", err)
+	}
+	if _, err := img.List("file/below"); err == nil {
+		t.Error("List(): expected error on path below a file")
+	}
+}
+
+func TestMetaBG(t *testing.T) {
+	// Small groups with few inodes spread the files over more groups than
+	// one block of 64 byte descriptors covers.
+	name, cleanup := mkimage(t, "16M", "-b", "1024", "-g", "256", "-N", "256",
+		"-O", "meta_bg,^resize_inode,64bit,ea_inode")
+	defer cleanup()
+
+	img, err := Open(name)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer img.Close()
+	if img.incompat&incompatMetaBG == 0 {
+		t.Skip("mkfs.ext4 didn't enable meta_bg")
+	}
+	perBlock := uint32(img.blockSize / img.descSize)
+
+	last := uint32(0)
+	for i := 0; i < fillers; i++ {
+		path := fmt.Sprintf("dir/filler-with-a-rather-long-name-%03d", i)
+		ino, err := img.lookup(path)
+		if err != nil {
+			t.Fatalf("lookup(%q) failed: %v", path, err)
+		}
+		if ino > last {
+			last = ino
+		}
+		if _, err := img.List(path); err != nil {
+			t.Errorf("List(%q) failed: %v", path, err)
+		}
+	}
+	if group := (last - 1) / img.inodesPerGroup; group < perBlock {
+		t.Errorf("files end in group %d, expected beyond the first %d", group, perBlock)
+	}
+
+	for _, a := range testAttrs {
+		if int64(len(a.value)) > img.blockSize {
+			// debugfs stores at most a block with 1K blocks.
+			continue
+		}
+		if got, err := img.Get(a.path, a.attr); err != nil || !bytes.Equal(got, a.value) {
+			t.Errorf("Get(%q, %q): got %d bytes, %v", a.path, a.attr, len(got), err)
+		}
+	}
+}
+
+func TestNotExt4(t *testing.T) {
+	if _, err := New(bytes.NewReader(make([]byte, 4096))); err == nil {
+		t.Error("New(): expected error on zeroed image")
+	}
+	if _, err := New(bytes.NewReader(nil)); err == nil {
+		t.Error("New(): expected error on empty image")
+	}
+}
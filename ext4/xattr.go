@@ -0,0 +1,234 @@
+package ext4
+
+import (
+	"encoding/binary"
+	"os"
+
+	"github.com/ivaxer/go-xattr/internal/errno"
+	"github.com/ivaxer/go-xattr/internal/posixacl"
+)
+
+const (
+	xattrMagic           = 0xea020000
+	xattrBlockHeaderSize = 32
+	xattrEntrySize       = 16
+	extraIsizeOffset     = 0x80
+	fileACLOffset        = 0x68
+	fileACLHighOffset    = 0x76
+
+	nameIndexACLAccess  = 2
+	nameIndexACLDefault = 3
+
+	ext4ACLVersion = 1
+)
+
+var namePrefixes = map[uint8]string{
+	1:                   "user.",
+	nameIndexACLAccess:  posixacl.AccessAttr,
+	nameIndexACLDefault: posixacl.DefaultAttr,
+	4:                   "trusted.",
+	6:                   "security.",
+	7:                   "system.",
+	8:                   "system.richacl",
+}
+
+// entry is a parsed attribute entry.
+type entry struct {
+	name  string
+	index uint8
+	value []byte // nil if stored in an EA inode
+	inum  uint32
+	size  uint32
+}
+
+// List retrieves the names of the extended attributes of the file at path
+// in the image. Symbolic links are not followed. If there is an error, it
+// will be of type *os.PathError.
+func (img *Image) List(path string) ([]string, error) {
+	entries, err := img.entries(path)
+	if err != nil {
+		return nil, &os.PathError{Op: "listxattr", Path: path, Err: err}
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.name)
+	}
+	return names, nil
+}
+
+// Get retrieves the value of the extended attribute attr of the file at
+// path in the image. POSIX ACLs are converted from the on-disk format to
+// the format returned by the system call. If there is an error, it will be
+// of type *os.PathError.
+func (img *Image) Get(path, attr string) ([]byte, error) {
+	entries, err := img.entries(path)
+	if err != nil {
+		return nil, &os.PathError{Op: "getxattr", Path: path, Err: err}
+	}
+
+	for _, e := range entries {
+		if e.name != attr {
+			continue
+		}
+		value, err := img.value(e)
+		if err != nil {
+			return nil, &os.PathError{Op: "getxattr", Path: path, Err: err}
+		}
+		return value, nil
+	}
+	return nil, &os.PathError{Op: "getxattr", Path: path, Err: errno.NoAttr}
+}
+
+// entries returns the attribute entries of the file at path, in-inode
+// entries first.
+func (img *Image) entries(path string) ([]entry, error) {
+	ino, err := img.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+	inode, err := img.readInode(ino)
+	if err != nil {
+		return nil, err
+	}
+	return img.inodeEntries(inode)
+}
+
+func (img *Image) inodeEntries(inode []byte) ([]entry, error) {
+	var entries []entry
+
+	if len(inode) > extraIsizeOffset+2 {
+		start := goodOldInodeSize + int(binary.LittleEndian.Uint16(inode[extraIsizeOffset:]))
+		if start+4 <= len(inode) && binary.LittleEndian.Uint32(inode[start:]) == xattrMagic {
+			// In-inode value offsets are relative to the first entry.
+			region := inode[start+4:]
+			e, err := parseEntries(region, region)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, e...)
+		}
+	}
+
+	blk := uint64(binary.LittleEndian.Uint32(inode[fileACLOffset:])) |
+		uint64(binary.LittleEndian.Uint16(inode[fileACLHighOffset:]))<<32
+	if blk != 0 {
+		b, err := img.readBlock(blk)
+		if err != nil {
+			return nil, err
+		}
+		if binary.LittleEndian.Uint32(b) != xattrMagic {
+			return nil, ErrCorrupt
+		}
+		e, err := parseEntries(b[xattrBlockHeaderSize:], b)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e...)
+	}
+	return entries, nil
+}
+
+// parseEntries parses the entry table at the start of b. Value offsets
+// are relative to base.
+func parseEntries(b, base []byte) ([]entry, error) {
+	var entries []entry
+	for off := 0; off+4 <= len(b) && binary.LittleEndian.Uint32(b[off:]) != 0; {
+		if off+xattrEntrySize > len(b) {
+			return nil, ErrCorrupt
+		}
+		nameLen := int(b[off])
+		index := b[off+1]
+		valueOffs := int(binary.LittleEndian.Uint16(b[off+2:]))
+		inum := binary.LittleEndian.Uint32(b[off+4:])
+		size := binary.LittleEndian.Uint32(b[off+8:])
+		if off+xattrEntrySize+nameLen > len(b) {
+			return nil, ErrCorrupt
+		}
+		suffix := string(b[off+xattrEntrySize : off+xattrEntrySize+nameLen])
+		off += (xattrEntrySize + nameLen + 3) &^ 3
+
+		prefix, ok := namePrefixes[index]
+		if !ok {
+			continue
+		}
+		e := entry{name: prefix + suffix, index: index, inum: inum, size: size}
+		if inum == 0 {
+			if valueOffs+int(size) > len(base) {
+				return nil, ErrCorrupt
+			}
+			e.value = base[valueOffs : valueOffs+int(size)]
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// value returns the value of the entry in the format of the system call.
+func (img *Image) value(e entry) ([]byte, error) {
+	value := e.value
+	if e.inum != 0 {
+		inode, err := img.readInode(e.inum)
+		if err != nil {
+			return nil, err
+		}
+		data, err := img.readData(inode)
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(data)) < uint64(e.size) {
+			return nil, ErrCorrupt
+		}
+		value = data[:e.size]
+	}
+
+	if e.index == nameIndexACLAccess || e.index == nameIndexACLDefault {
+		return convertACL(value)
+	}
+	return append([]byte{}, value...), nil
+}
+
+// inlineData returns the part of inline file data stored in the
+// system.data attribute.
+func (img *Image) inlineData(inode []byte) ([]byte, error) {
+	entries, err := img.inodeEntries(inode)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.name == "system.data" && e.inum == 0 {
+			return e.value, nil
+		}
+	}
+	return nil, nil
+}
+
+// convertACL converts an ACL from the ext4 on-disk format, where entries
+// without an ID are shortened, to the extended attribute format.
+func convertACL(b []byte) ([]byte, error) {
+	if len(b) < 4 || binary.LittleEndian.Uint32(b) != ext4ACLVersion {
+		return nil, ErrCorrupt
+	}
+
+	var acl posixacl.ACL
+	for p := b[4:]; len(p) > 0; {
+		if len(p) < 4 {
+			return nil, ErrCorrupt
+		}
+		e := posixacl.Entry{
+			Tag:  binary.LittleEndian.Uint16(p),
+			Perm: binary.LittleEndian.Uint16(p[2:]),
+			ID:   posixacl.UndefinedID,
+		}
+		p = p[4:]
+		if e.Tag == posixacl.User || e.Tag == posixacl.Group {
+			if len(p) < 4 {
+				return nil, ErrCorrupt
+			}
+			e.ID = binary.LittleEndian.Uint32(p)
+			p = p[4:]
+		}
+		acl = append(acl, e)
+	}
+	return acl.Marshal(), nil
+}
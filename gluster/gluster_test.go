@@ -0,0 +1,52 @@
+package gluster
+
+import (
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    []byte
+		expected string
+	}{
+		{
+			GFIDAttr,
+			[]byte{0x7e, 0x2e, 0x8c, 0x4b, 0x66, 0x1a, 0x4e, 0x4f, 0x9a, 0x1c, 0x3c, 0x5e, 0x2a, 0x70, 0x8e, 0x01},
+			"7e2e8c4b-661a-4e4f-9a1c-3c5e2a708e01",
+		},
+		{
+			"trusted.afr.gv0-client-1",
+			[]byte{0, 0, 0, 2, 0, 0, 0, 1, 0, 0, 0, 0},
+			"data=2 metadata=1 entry=0",
+		},
+		{
+			DHTAttr,
+			[]byte{0x3c, 0x4a, 0x11, 0x2f, 0, 0, 0, 0, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			"commit=0x3c4a112f type=0 range=0x7fffffff-0xffffffff",
+		},
+	}
+
+	for _, test := range tests {
+		v, err := Decode(test.name, test.value)
+		if err != nil {
+			t.Errorf("Decode(%q) failed: %v", test.name, err)
+			continue
+		}
+		if got := v.String(); got != test.expected {
+			t.Errorf("Decode(%q): got %q, expected %q", test.name, got, test.expected)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	if _, err := Decode("user.foo", nil); err != ErrUnknownAttr {
+		t.Errorf("Decode(user.foo): unexpected error value: %v", err)
+	}
+	if _, err := Decode(GFIDAttr, []byte{1, 2, 3}); err == nil {
+		t.Error("Decode(trusted.gfid): expected error on short value")
+	}
+	if _, err := Decode(DHTAttr, make([]byte, 12)); err == nil {
+		t.Error("Decode(trusted.glusterfs.dht): expected error on short value")
+	}
+}
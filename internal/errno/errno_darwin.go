@@ -0,0 +1,10 @@
+// Package errno provides the system error numbers that xattr.IsNotExist
+// recognizes, for packages emulating the system calls.
+package errno
+
+import (
+	"syscall"
+)
+
+// NoAttr is returned when an extended attribute does not exist.
+const NoAttr = syscall.ENOATTR
@@ -0,0 +1,133 @@
+// Package posixacl decodes and encodes POSIX access control lists in the
+// format the kernel uses for system.posix_acl_access and
+// system.posix_acl_default.
+package posixacl
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Attribute names.
+const (
+	AccessAttr  = "system.posix_acl_access"
+	DefaultAttr = "system.posix_acl_default"
+)
+
+const (
+	version   = 2
+	entrySize = 8
+)
+
+// Entry tags.
+const (
+	UserObj  = 0x01
+	User     = 0x02
+	GroupObj = 0x04
+	Group    = 0x08
+	Mask     = 0x10
+	Other    = 0x20
+)
+
+// Permission bits.
+const (
+	Execute = 0x1
+	Write   = 0x2
+	Read    = 0x4
+)
+
+// UndefinedID is the ID of entries that don't name a user or group.
+const UndefinedID = 0xffffffff
+
+// Entry is an ACL entry. ID is only meaningful for User and Group tags.
+type Entry struct {
+	Tag  uint16
+	Perm uint16
+	ID   uint32
+}
+
+// ACL is a list of ACL entries.
+type ACL []Entry
+
+// Parse decodes an ACL in the extended attribute format.
+func Parse(b []byte) (ACL, error) {
+	if len(b) < 4 || (len(b)-4)%entrySize != 0 {
+		return nil, fmt.Errorf("posixacl: invalid ACL length %d", len(b))
+	}
+	if v := binary.LittleEndian.Uint32(b); v != version {
+		return nil, fmt.Errorf("posixacl: unsupported ACL version %d", v)
+	}
+
+	acl := make(ACL, 0, (len(b)-4)/entrySize)
+	for p := b[4:]; len(p) > 0; p = p[entrySize:] {
+		acl = append(acl, Entry{
+			Tag:  binary.LittleEndian.Uint16(p[0:]),
+			Perm: binary.LittleEndian.Uint16(p[2:]),
+			ID:   binary.LittleEndian.Uint32(p[4:]),
+		})
+	}
+	return acl, nil
+}
+
+// Marshal encodes the ACL in the extended attribute format.
+func (acl ACL) Marshal() []byte {
+	b := make([]byte, 4, 4+entrySize*len(acl))
+	binary.LittleEndian.PutUint32(b, version)
+	for _, e := range acl {
+		id := e.ID
+		if e.Tag != User && e.Tag != Group {
+			id = UndefinedID
+		}
+		b = binary.LittleEndian.AppendUint16(b, e.Tag)
+		b = binary.LittleEndian.AppendUint16(b, e.Perm)
+		b = binary.LittleEndian.AppendUint32(b, id)
+	}
+	return b
+}
+
+// String renders the ACL in the short text form, e.g.
+// "user::rw-,user:1000:r--,group::r--,mask::r--,other::---".
+func (acl ACL) String() string {
+	s := make([]string, len(acl))
+	for i, e := range acl {
+		s[i] = e.String()
+	}
+	return strings.Join(s, ",")
+}
+
+func (e Entry) String() string {
+	var tag, qualifier string
+	switch e.Tag {
+	case UserObj:
+		tag = "user"
+	case User:
+		tag, qualifier = "user", strconv.FormatUint(uint64(e.ID), 10)
+	case GroupObj:
+		tag = "group"
+	case Group:
+		tag, qualifier = "group", strconv.FormatUint(uint64(e.ID), 10)
+	case Mask:
+		tag = "mask"
+	case Other:
+		tag = "other"
+	default:
+		tag = fmt.Sprintf("tag(0x%x)", e.Tag)
+	}
+	return tag + ":" + qualifier + ":" + permString(e.Perm)
+}
+
+func permString(p uint16) string {
+	b := []byte("---")
+	if p&Read != 0 {
+		b[0] = 'r'
+	}
+	if p&Write != 0 {
+		b[1] = 'w'
+	}
+	if p&Execute != 0 {
+		b[2] = 'x'
+	}
+	return string(b)
+}
@@ -0,0 +1,38 @@
+package posixacl
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	acl := ACL{
+		{Tag: UserObj, Perm: Read | Write},
+		{Tag: User, Perm: Read, ID: 1000},
+		{Tag: GroupObj, Perm: Read},
+		{Tag: Mask, Perm: Read},
+		{Tag: Other},
+	}
+
+	b := acl.Marshal()
+	got, err := Parse(b)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if !bytes.Equal(got.Marshal(), b) {
+		t.Errorf("Marshal(Parse(b)) != b")
+	}
+
+	expected := "user::rw-,user:1000:r--,group::r--,mask::r--,other::---"
+	if s := got.String(); s != expected {
+		t.Errorf("String(): got %q, expected %q", s, expected)
+	}
+
+	if _, err := Parse(b[:7]); err == nil {
+		t.Error("Parse(): expected error on truncated ACL")
+	}
+	b[0] = 1
+	if _, err := Parse(b); err == nil {
+		t.Error("Parse(): expected error on unknown version")
+	}
+}
@@ -0,0 +1,168 @@
+// Package vfscap decodes and encodes Linux file capabilities stored in
+// security.capability.
+package vfscap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Attr is the name of the attribute holding file capabilities.
+const Attr = "security.capability"
+
+// Revisions of the on-disk format.
+const (
+	Revision1 = 1 // 32-bit capability sets
+	Revision2 = 2 // 64-bit capability sets
+	Revision3 = 3 // 64-bit capability sets with a namespace root ID
+)
+
+const (
+	revisionMask  = 0xff000000
+	revisionShift = 24
+	flagEffective = 0x000001
+)
+
+var sizes = map[int]int{Revision1: 12, Revision2: 20, Revision3: 24}
+
+// Names of the capabilities, indexed by capability number.
+var Names = []string{
+	"cap_chown", "cap_dac_override", "cap_dac_read_search", "cap_fowner",
+	"cap_fsetid", "cap_kill", "cap_setgid", "cap_setuid", "cap_setpcap",
+	"cap_linux_immutable", "cap_net_bind_service", "cap_net_broadcast",
+	"cap_net_admin", "cap_net_raw", "cap_ipc_lock", "cap_ipc_owner",
+	"cap_sys_module", "cap_sys_rawio", "cap_sys_chroot", "cap_sys_ptrace",
+	"cap_sys_pacct", "cap_sys_admin", "cap_sys_boot", "cap_sys_nice",
+	"cap_sys_resource", "cap_sys_time", "cap_sys_tty_config", "cap_mknod",
+	"cap_lease", "cap_audit_write", "cap_audit_control", "cap_setfcap",
+	"cap_mac_override", "cap_mac_admin", "cap_syslog", "cap_wake_alarm",
+	"cap_block_suspend", "cap_audit_read", "cap_perfmon", "cap_bpf",
+	"cap_checkpoint_restore",
+}
+
+// Set is a set of capabilities, bit n standing for capability n.
+type Set uint64
+
+// Names returns the names of the capabilities in the set.
+func (s Set) Names() []string {
+	var names []string
+	for i := uint(0); i < 64; i++ {
+		if s&(1<<i) != 0 {
+			names = append(names, name(i))
+		}
+	}
+	return names
+}
+
+func name(i uint) string {
+	if int(i) < len(Names) {
+		return Names[i]
+	}
+	return fmt.Sprintf("cap_%d", i)
+}
+
+// Capability is the content of security.capability.
+type Capability struct {
+	Revision    int
+	Effective   bool
+	Permitted   Set
+	Inheritable Set
+	RootID      uint32 // Revision3 only
+}
+
+// Parse decodes the value of security.capability.
+func Parse(b []byte) (*Capability, error) {
+	if len(b) < 4 {
+		return nil, fmt.Errorf("vfscap: invalid capability length %d", len(b))
+	}
+
+	magic := binary.LittleEndian.Uint32(b)
+	c := &Capability{
+		Revision:  int(magic&revisionMask) >> revisionShift,
+		Effective: magic&flagEffective != 0,
+	}
+
+	size, ok := sizes[c.Revision]
+	if !ok {
+		return nil, fmt.Errorf("vfscap: unsupported capability revision %d", c.Revision)
+	}
+	if len(b) != size {
+		return nil, fmt.Errorf("vfscap: invalid revision %d capability length %d", c.Revision, len(b))
+	}
+
+	c.Permitted = Set(binary.LittleEndian.Uint32(b[4:]))
+	c.Inheritable = Set(binary.LittleEndian.Uint32(b[8:]))
+	if c.Revision >= Revision2 {
+		c.Permitted |= Set(binary.LittleEndian.Uint32(b[12:])) << 32
+		c.Inheritable |= Set(binary.LittleEndian.Uint32(b[16:])) << 32
+	}
+	if c.Revision == Revision3 {
+		c.RootID = binary.LittleEndian.Uint32(b[20:])
+	}
+	return c, nil
+}
+
+// Marshal encodes the capability in the format of its revision.
+// Revision1 can only hold the first 32 capabilities.
+func (c *Capability) Marshal() ([]byte, error) {
+	size, ok := sizes[c.Revision]
+	if !ok {
+		return nil, fmt.Errorf("vfscap: unsupported capability revision %d", c.Revision)
+	}
+	if c.Revision == Revision1 && (c.Permitted|c.Inheritable)>>32 != 0 {
+		return nil, fmt.Errorf("vfscap: capabilities above 31 don't fit revision 1")
+	}
+
+	b := make([]byte, size)
+	magic := uint32(c.Revision) << revisionShift
+	if c.Effective {
+		magic |= flagEffective
+	}
+	binary.LittleEndian.PutUint32(b, magic)
+	binary.LittleEndian.PutUint32(b[4:], uint32(c.Permitted))
+	binary.LittleEndian.PutUint32(b[8:], uint32(c.Inheritable))
+	if c.Revision >= Revision2 {
+		binary.LittleEndian.PutUint32(b[12:], uint32(c.Permitted>>32))
+		binary.LittleEndian.PutUint32(b[16:], uint32(c.Inheritable>>32))
+	}
+	if c.Revision == Revision3 {
+		binary.LittleEndian.PutUint32(b[20:], c.RootID)
+	}
+	return b, nil
+}
+
+// String renders the capability in the textual form of getcap(8), e.g.
+// "cap_net_admin,cap_net_raw=ep".
+func (c *Capability) String() string {
+	groups := make(map[string][]string)
+	for i := uint(0); i < 64; i++ {
+		bit := Set(1) << i
+		var flags string
+		if c.Effective && (c.Permitted|c.Inheritable)&bit != 0 {
+			flags += "e"
+		}
+		if c.Inheritable&bit != 0 {
+			flags += "i"
+		}
+		if c.Permitted&bit != 0 {
+			flags += "p"
+		}
+		if flags != "" {
+			groups[flags] = append(groups[flags], name(i))
+		}
+	}
+
+	var clauses []string
+	for flags, names := range groups {
+		clauses = append(clauses, strings.Join(names, ",")+"="+flags)
+	}
+	sort.Strings(clauses)
+
+	s := strings.Join(clauses, " ")
+	if c.Revision == Revision3 {
+		s += fmt.Sprintf(" [rootid=%d]", c.RootID)
+	}
+	return s
+}
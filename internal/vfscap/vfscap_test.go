@@ -0,0 +1,57 @@
+package vfscap
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value    []byte
+		expected string
+	}{
+		{
+			[]byte{1, 0, 0, 2, 0, 0x30, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+			"cap_net_admin,cap_net_raw=ep",
+		},
+		{
+			[]byte{0, 0, 0, 2, 0, 0x04, 0, 0, 0, 0x04, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+			"cap_net_bind_service=ip",
+		},
+		{
+			[]byte{1, 0, 0, 3, 0, 0x04, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xe8, 0x03, 0, 0},
+			"cap_net_bind_service=ep [rootid=1000]",
+		},
+	}
+
+	for _, test := range tests {
+		c, err := Parse(test.value)
+		if err != nil {
+			t.Errorf("Parse(%x) failed: %v", test.value, err)
+			continue
+		}
+		if got := c.String(); got != test.expected {
+			t.Errorf("Parse(%x): got %q, expected %q", test.value, got, test.expected)
+		}
+
+		b, err := c.Marshal()
+		if err != nil {
+			t.Errorf("Marshal() failed: %v", err)
+		} else if !bytes.Equal(b, test.value) {
+			t.Errorf("Marshal(): got %x, expected %x", b, test.value)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, b := range [][]byte{nil, {0, 0, 0, 2}, {0, 0, 0, 9, 0, 0, 0, 0, 0, 0, 0, 0}} {
+		if _, err := Parse(b); err == nil {
+			t.Errorf("Parse(%x): expected error", b)
+		}
+	}
+
+	c := &Capability{Revision: Revision1, Permitted: 1 << 38}
+	if _, err := c.Marshal(); err == nil {
+		t.Error("Marshal(): expected error for revision 1 with high capabilities")
+	}
+}
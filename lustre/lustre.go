@@ -0,0 +1,200 @@
+// Package lustre decodes the internal extended attributes Lustre keeps on
+// its targets and exposes to clients.
+//
+// lustre.lov (trusted.lov on the MDT) holds the striping of a file over
+// OSTs and trusted.lma holds the FID of an MDT inode.
+package lustre
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Attribute names.
+const (
+	LOVAttr        = "lustre.lov"
+	TrustedLOVAttr = "trusted.lov"
+	LMAAttr        = "trusted.lma"
+)
+
+// Layout magics.
+const (
+	LOVMagicV1     = 0x0BD10BD0
+	LOVMagicV3     = 0x0BD30BD0
+	LOVMagicCompV1 = 0x0BD60BD0
+)
+
+const (
+	lovV1HeaderSize = 32
+	lovPoolNameLen  = 16
+	lovObjectSize   = 24
+)
+
+// ErrUnknownAttr is returned by Decode for attributes it doesn't know.
+var ErrUnknownAttr = errors.New("lustre: unknown attribute")
+
+// FID is a Lustre file identifier.
+type FID struct {
+	Seq uint64
+	OID uint32
+	Ver uint32
+}
+
+func (f FID) String() string {
+	return fmt.Sprintf("[0x%x:0x%x:0x%x]", f.Seq, f.OID, f.Ver)
+}
+
+func parseFID(b []byte) FID {
+	return FID{
+		Seq: binary.LittleEndian.Uint64(b[0:]),
+		OID: binary.LittleEndian.Uint32(b[8:]),
+		Ver: binary.LittleEndian.Uint32(b[12:]),
+	}
+}
+
+// LMA is the content of trusted.lma.
+type LMA struct {
+	Compat   uint32
+	Incompat uint32
+	Self     FID
+}
+
+// ParseLMA decodes the value of trusted.lma.
+func ParseLMA(b []byte) (LMA, error) {
+	if len(b) < 24 {
+		return LMA{}, fmt.Errorf("lustre: invalid LMA length %d", len(b))
+	}
+	return LMA{
+		Compat:   binary.LittleEndian.Uint32(b[0:]),
+		Incompat: binary.LittleEndian.Uint32(b[4:]),
+		Self:     parseFID(b[8:]),
+	}, nil
+}
+
+func (l LMA) String() string {
+	return fmt.Sprintf("fid=%v compat=0x%x incompat=0x%x", l.Self, l.Compat, l.Incompat)
+}
+
+// OSTID identifies an object on an OST.
+type OSTID struct {
+	ID  uint64
+	Seq uint64
+}
+
+func (o OSTID) String() string {
+	return fmt.Sprintf("0x%x:0x%x", o.Seq, o.ID)
+}
+
+func parseOSTID(b []byte) OSTID {
+	return OSTID{
+		ID:  binary.LittleEndian.Uint64(b[0:]),
+		Seq: binary.LittleEndian.Uint64(b[8:]),
+	}
+}
+
+// Object is one stripe of a file.
+type Object struct {
+	OI    OSTID
+	Gen   uint32
+	Index uint32
+}
+
+// LOV is a plain (V1 or V3) striping layout.
+type LOV struct {
+	Magic       uint32
+	Pattern     uint32
+	OI          OSTID
+	StripeSize  uint32
+	StripeCount uint16
+	LayoutGen   uint16
+	Pool        string // V3 only
+	Objects     []Object
+}
+
+// ParseLOV decodes the value of lustre.lov or trusted.lov. Composite
+// (PFL/FLR) layouts are not supported.
+func ParseLOV(b []byte) (*LOV, error) {
+	if len(b) < lovV1HeaderSize {
+		return nil, fmt.Errorf("lustre: invalid LOV length %d", len(b))
+	}
+
+	lov := &LOV{
+		Magic:       binary.LittleEndian.Uint32(b[0:]),
+		Pattern:     binary.LittleEndian.Uint32(b[4:]),
+		OI:          parseOSTID(b[8:]),
+		StripeSize:  binary.LittleEndian.Uint32(b[24:]),
+		StripeCount: binary.LittleEndian.Uint16(b[28:]),
+		LayoutGen:   binary.LittleEndian.Uint16(b[30:]),
+	}
+
+	p := b[lovV1HeaderSize:]
+	switch lov.Magic {
+	case LOVMagicV1:
+	case LOVMagicV3:
+		if len(p) < lovPoolNameLen {
+			return nil, fmt.Errorf("lustre: invalid LOV length %d", len(b))
+		}
+		name := p[:lovPoolNameLen]
+		if i := bytes.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+		lov.Pool = string(name)
+		p = p[lovPoolNameLen:]
+	case LOVMagicCompV1:
+		return nil, errors.New("lustre: composite layouts are not supported")
+	default:
+		return nil, fmt.Errorf("lustre: unknown LOV magic 0x%08x", lov.Magic)
+	}
+
+	// Files without allocated objects have a stripe count but no objects.
+	n := len(p) / lovObjectSize
+	if n > int(lov.StripeCount) {
+		n = int(lov.StripeCount)
+	}
+	for i := 0; i < n; i++ {
+		o := p[i*lovObjectSize:]
+		lov.Objects = append(lov.Objects, Object{
+			OI:    parseOSTID(o),
+			Gen:   binary.LittleEndian.Uint32(o[16:]),
+			Index: binary.LittleEndian.Uint32(o[20:]),
+		})
+	}
+	return lov, nil
+}
+
+// String renders the layout similarly to lfs getstripe.
+func (lov *LOV) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "stripe_count=%d stripe_size=%d pattern=0x%x layout_gen=%d",
+		lov.StripeCount, lov.StripeSize, lov.Pattern, lov.LayoutGen)
+	if lov.Pool != "" {
+		fmt.Fprintf(&b, " pool=%s", lov.Pool)
+	}
+	for _, o := range lov.Objects {
+		fmt.Fprintf(&b, " [ost=%d obj=%v]", o.Index, o.OI)
+	}
+	return b.String()
+}
+
+// Decode decodes the value of a Lustre attribute identified by name. It
+// returns ErrUnknownAttr for attributes it doesn't know.
+func Decode(name string, value []byte) (fmt.Stringer, error) {
+	switch name {
+	case LOVAttr, TrustedLOVAttr:
+		lov, err := ParseLOV(value)
+		if err != nil {
+			return nil, err
+		}
+		return lov, nil
+	case LMAAttr:
+		lma, err := ParseLMA(value)
+		if err != nil {
+			return nil, err
+		}
+		return lma, nil
+	}
+	return nil, ErrUnknownAttr
+}
@@ -0,0 +1,75 @@
+package lustre
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func lovV3(count uint16, pool string, objects ...Object) []byte {
+	b := make([]byte, lovV1HeaderSize+lovPoolNameLen)
+	binary.LittleEndian.PutUint32(b[0:], LOVMagicV3)
+	binary.LittleEndian.PutUint32(b[4:], 1)
+	binary.LittleEndian.PutUint64(b[8:], 0x2)
+	binary.LittleEndian.PutUint64(b[16:], 0x200000401)
+	binary.LittleEndian.PutUint32(b[24:], 1<<20)
+	binary.LittleEndian.PutUint16(b[28:], count)
+	copy(b[lovV1HeaderSize:], pool)
+	for _, o := range objects {
+		ob := make([]byte, lovObjectSize)
+		binary.LittleEndian.PutUint64(ob[0:], o.OI.ID)
+		binary.LittleEndian.PutUint64(ob[8:], o.OI.Seq)
+		binary.LittleEndian.PutUint32(ob[16:], o.Gen)
+		binary.LittleEndian.PutUint32(ob[20:], o.Index)
+		b = append(b, ob...)
+	}
+	return b
+}
+
+func TestParseLOV(t *testing.T) {
+	b := lovV3(2, "flash",
+		Object{OI: OSTID{ID: 0x21, Seq: 0x100000000}, Index: 0},
+		Object{OI: OSTID{ID: 0x43, Seq: 0x100010000}, Index: 1})
+
+	lov, err := ParseLOV(b)
+	if err != nil {
+		t.Fatalf("ParseLOV() failed: %v", err)
+	}
+	if lov.Pool != "flash" || lov.StripeCount != 2 || len(lov.Objects) != 2 {
+		t.Fatalf("ParseLOV(): unexpected layout %+v", lov)
+	}
+
+	expected := "stripe_count=2 stripe_size=1048576 pattern=0x1 layout_gen=0 pool=flash" +
+		" [ost=0 obj=0x100000000:0x21] [ost=1 obj=0x100010000:0x43]"
+	if got := lov.String(); got != expected {
+		t.Errorf("LOV.String(): got %q, expected %q", got, expected)
+	}
+
+	if _, err := ParseLOV(b[:20]); err == nil {
+		t.Error("ParseLOV(): expected error on short value")
+	}
+	binary.LittleEndian.PutUint32(b, LOVMagicCompV1)
+	if _, err := ParseLOV(b); err == nil {
+		t.Error("ParseLOV(): expected error on composite layout")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	b := make([]byte, 24)
+	binary.LittleEndian.PutUint64(b[8:], 0x200000401)
+	binary.LittleEndian.PutUint32(b[16:], 0x1)
+
+	v, err := Decode(LMAAttr, b)
+	if err != nil {
+		t.Fatalf("Decode(trusted.lma) failed: %v", err)
+	}
+	if got, expected := v.String(), "fid=[0x200000401:0x1:0x0] compat=0x0 incompat=0x0"; got != expected {
+		t.Errorf("Decode(trusted.lma): got %q, expected %q", got, expected)
+	}
+
+	if _, err := Decode(LOVAttr, nil); err == nil || err == ErrUnknownAttr {
+		t.Errorf("Decode(lustre.lov): unexpected error value: %v", err)
+	}
+	if _, err := Decode("user.foo", nil); err != ErrUnknownAttr {
+		t.Errorf("Decode(user.foo): unexpected error value: %v", err)
+	}
+}
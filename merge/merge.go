@@ -0,0 +1,241 @@
+// Package merge reconciles the extended attributes of two replicas of a
+// file that were both modified since they were last in sync.
+//
+// Attribute sets are maps from names to values. Merge compares each name
+// across the common ancestor (base) and the two replicas: a change made
+// on one side only is taken, identical changes on both sides are taken
+// once, and different changes are conflicts handed to a Strategy. Apply
+// writes the result to a file.
+package merge
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ivaxer/go-xattr"
+)
+
+var (
+	// ErrUnresolved is returned by strategies that can't decide a
+	// conflict.
+	ErrUnresolved = errors.New("merge: conflict not resolved")
+	// ErrConflict is returned by Merge when conflicts remain unresolved.
+	ErrConflict = errors.New("merge: unresolved conflicts")
+)
+
+// Conflict is an attribute changed differently on both sides. Absent
+// values are nil; present empty values are non-nil.
+type Conflict struct {
+	Name   string
+	Base   []byte
+	Ours   []byte
+	Theirs []byte
+
+	// Resolved reports whether the strategy decided the conflict, and
+	// Value is its decision, nil meaning the attribute is removed.
+	Resolved bool
+	Value    []byte
+}
+
+func (c *Conflict) String() string {
+	return fmt.Sprintf("%s: base %s, ours %s, theirs %s", c.Name, show(c.Base), show(c.Ours), show(c.Theirs))
+}
+
+func show(v []byte) string {
+	if v == nil {
+		return "absent"
+	}
+	return strconv.Quote(string(v))
+}
+
+// Strategy resolves a conflict given both complete attribute sets. It
+// returns the merged value, nil to remove the attribute, or ErrUnresolved.
+type Strategy func(c *Conflict, ours, theirs map[string][]byte) ([]byte, error)
+
+// Ours resolves conflicts in favour of our side.
+func Ours(c *Conflict, ours, theirs map[string][]byte) ([]byte, error) {
+	return c.Ours, nil
+}
+
+// Theirs resolves conflicts in favour of their side.
+func Theirs(c *Conflict, ours, theirs map[string][]byte) ([]byte, error) {
+	return c.Theirs, nil
+}
+
+// Newest returns a strategy taking the side whose attribute attr holds the
+// later time. Times are Unix seconds, with an optional fraction, or RFC
+// 3339. Conflicts where either time is missing or invalid, or both are
+// equal, are left unresolved; use Chain to fall back to another strategy.
+func Newest(attr string) Strategy {
+	return func(c *Conflict, ours, theirs map[string][]byte) ([]byte, error) {
+		o, ok1 := parseTime(ours[attr])
+		t, ok2 := parseTime(theirs[attr])
+		switch {
+		case !ok1 || !ok2 || o.Equal(t):
+			return nil, ErrUnresolved
+		case o.After(t):
+			return c.Ours, nil
+		}
+		return c.Theirs, nil
+	}
+}
+
+func parseTime(b []byte) (time.Time, bool) {
+	s := strings.TrimSpace(string(b))
+	if s == "" {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, true
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	sec := int64(f)
+	return time.Unix(sec, int64((f-float64(sec))*1e9)), true
+}
+
+// Chain returns a strategy trying each of strategies in turn until one
+// resolves the conflict.
+func Chain(strategies ...Strategy) Strategy {
+	return func(c *Conflict, ours, theirs map[string][]byte) ([]byte, error) {
+		for _, s := range strategies {
+			v, err := s(c, ours, theirs)
+			if err != ErrUnresolved {
+				return v, err
+			}
+		}
+		return nil, ErrUnresolved
+	}
+}
+
+// Merge merges the changes made to base in ours and theirs. Conflicts are
+// resolved with s, which may be nil. It returns the merged set and every
+// conflict, in name order. If some conflicts remain unresolved, they keep
+// our value in the merged set and Merge returns ErrConflict along with the
+// result. Errors from s other than ErrUnresolved abort the merge.
+func Merge(base, ours, theirs map[string][]byte, s Strategy) (map[string][]byte, []*Conflict, error) {
+	names := make(map[string]bool)
+	for _, m := range []map[string][]byte{base, ours, theirs} {
+		for name := range m {
+			names[name] = true
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	merged := make(map[string][]byte)
+	var conflicts []*Conflict
+	unresolved := false
+	for _, name := range sorted {
+		b, o, t := value(base, name), value(ours, name), value(theirs, name)
+
+		var v []byte
+		switch {
+		case equal(o, t), equal(t, b):
+			v = o
+		case equal(o, b):
+			v = t
+		default:
+			c := &Conflict{Name: name, Base: b, Ours: o, Theirs: t}
+			conflicts = append(conflicts, c)
+			v = o
+			if s != nil {
+				r, err := s(c, ours, theirs)
+				switch err {
+				case nil:
+					c.Resolved, c.Value, v = true, r, r
+				case ErrUnresolved:
+				default:
+					return nil, nil, err
+				}
+			}
+			if !c.Resolved {
+				unresolved = true
+			}
+		}
+		if v != nil {
+			merged[name] = v
+		}
+	}
+
+	if unresolved {
+		return merged, conflicts, ErrConflict
+	}
+	return merged, conflicts, nil
+}
+
+// value returns the value of name in m, non-nil if present.
+func value(m map[string][]byte, name string) []byte {
+	v, ok := m[name]
+	if !ok {
+		return nil
+	}
+	if v == nil {
+		v = []byte{}
+	}
+	return v
+}
+
+func equal(a, b []byte) bool {
+	return (a == nil) == (b == nil) && bytes.Equal(a, b)
+}
+
+// Load returns the attributes of path.
+func Load(path string) (map[string][]byte, error) {
+	names, err := xattr.List(path)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string][]byte, len(names))
+	for _, name := range names {
+		v, err := xattr.Get(path, name)
+		if err != nil {
+			if xattr.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		m[name] = v
+	}
+	return m, nil
+}
+
+// Apply changes the attributes of path from current, usually what Load
+// returned before merging, to merged: changed values are set and names
+// missing from merged are removed. Attributes not in either set are left
+// alone.
+func Apply(path string, current, merged map[string][]byte) error {
+	names := make([]string, 0, len(merged))
+	for name := range merged {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if v, ok := current[name]; ok && bytes.Equal(v, merged[name]) {
+			continue
+		}
+		if err := xattr.Set(path, name, merged[name]); err != nil {
+			return err
+		}
+	}
+
+	for name := range current {
+		if _, ok := merged[name]; ok {
+			continue
+		}
+		if err := xattr.Remove(path, name); err != nil && !xattr.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
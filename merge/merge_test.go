@@ -0,0 +1,167 @@
+package merge
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ivaxer/go-xattr"
+	"github.com/ivaxer/go-xattr/xattrtest"
+)
+
+func attrs(kv ...string) map[string][]byte {
+	m := make(map[string][]byte)
+	for i := 0; i < len(kv); i += 2 {
+		m[kv[i]] = []byte(kv[i+1])
+	}
+	return m
+}
+
+var (
+	base = attrs(
+		"user.same", "1",
+		"user.ours", "1",
+		"user.theirs", "1",
+		"user.both", "1",
+		"user.removed", "1",
+		"user.clash", "1",
+		"user.gone", "1",
+	)
+	ours = attrs(
+		"user.same", "1",
+		"user.ours", "2",
+		"user.theirs", "1",
+		"user.both", "2",
+		"user.clash", "ours",
+		"user.gone", "changed",
+		"user.added", "x",
+		"user.mtime", "1700000100",
+	)
+	theirs = attrs(
+		"user.same", "1",
+		"user.ours", "1",
+		"user.theirs", "2",
+		"user.both", "2",
+		"user.removed", "1",
+		"user.clash", "theirs",
+		"user.added", "y",
+		"user.mtime", "2023-11-14T22:13:20Z",
+	)
+)
+
+func TestMerge(t *testing.T) {
+	merged, conflicts, err := Merge(base, ours, theirs, nil)
+	if err != ErrConflict {
+		t.Errorf("Merge(): got %v, expected ErrConflict", err)
+	}
+	var names []string
+	for _, c := range conflicts {
+		names = append(names, c.Name)
+		if c.Resolved {
+			t.Errorf("Merge(): %s resolved without a strategy", c.Name)
+		}
+	}
+	if expected := []string{"user.added", "user.clash", "user.gone", "user.mtime"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Merge(): got conflicts %v, expected %v", names, expected)
+	}
+	if conflicts[2].Theirs != nil || conflicts[0].Base != nil {
+		t.Errorf("Merge(): absent values not nil: %v %v", conflicts[2], conflicts[0])
+	}
+
+	expected := attrs(
+		"user.same", "1",
+		"user.ours", "2",
+		"user.theirs", "2",
+		"user.both", "2",
+		"user.clash", "ours",
+		"user.gone", "changed",
+		"user.added", "x",
+		"user.mtime", "1700000100",
+	)
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("Merge(): got %q, expected %q", merged, expected)
+	}
+
+	merged, _, err = Merge(base, ours, theirs, Theirs)
+	if err != nil {
+		t.Fatalf("Merge(Theirs) failed: %v", err)
+	}
+	if _, ok := merged["user.gone"]; ok || string(merged["user.clash"]) != "theirs" {
+		t.Errorf("Merge(Theirs): got %q", merged)
+	}
+}
+
+func TestNewest(t *testing.T) {
+	// ours: 1700000100, theirs: 1700000000.
+	merged, _, err := Merge(base, ours, theirs, Newest("user.mtime"))
+	if err != nil {
+		t.Fatalf("Merge(Newest) failed: %v", err)
+	}
+	if string(merged["user.clash"]) != "ours" {
+		t.Errorf("Merge(Newest): got %q", merged["user.clash"])
+	}
+
+	_, conflicts, err := Merge(base, ours, theirs, Newest("user.missing"))
+	if err != ErrConflict || len(conflicts) != 4 {
+		t.Errorf("Merge(Newest): got %v, expected ErrConflict", err)
+	}
+
+	merged, _, err = Merge(base, ours, theirs, Chain(Newest("user.missing"), Theirs))
+	if err != nil || string(merged["user.clash"]) != "theirs" {
+		t.Errorf("Merge(Chain): got %q, %v", merged["user.clash"], err)
+	}
+
+	errBad := errors.New("bad")
+	custom := func(c *Conflict, ours, theirs map[string][]byte) ([]byte, error) {
+		switch c.Name {
+		case "user.added":
+			return append(append([]byte{}, c.Ours...), c.Theirs...), nil
+		case "user.gone":
+			return nil, errBad
+		}
+		return nil, ErrUnresolved
+	}
+	merged, _, err = Merge(base, ours, theirs, Chain(custom, Ours))
+	if err != errBad {
+		t.Errorf("Merge(custom): got %v, expected custom error", err)
+	}
+	delete(ours, "user.gone")
+	defer func() { ours["user.gone"] = []byte("changed") }()
+	merged, _, err = Merge(base, ours, theirs, Chain(custom, Ours))
+	if err != nil || string(merged["user.added"]) != "xy" {
+		t.Errorf("Merge(custom): got %q, %v", merged["user.added"], err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	path := xattrtest.TempFile(t)
+
+	for name, value := range ours {
+		xattr.Set(path, name, value)
+	}
+	xattr.Set(path, "user.unrelated", []byte("keep"))
+
+	current, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	delete(current, "user.unrelated")
+
+	merged, _, err := Merge(base, current, theirs, Theirs)
+	if err != nil {
+		t.Fatalf("Merge() failed: %v", err)
+	}
+	if err := Apply(path, current, merged); err != nil {
+		t.Fatalf("Apply() failed: %v", err)
+	}
+
+	got, _ := Load(path)
+	if !bytes.Equal(got["user.unrelated"], []byte("keep")) {
+		t.Errorf("Apply(): touched an unrelated attribute")
+	}
+	delete(got, "user.unrelated")
+	if !reflect.DeepEqual(got, merged) {
+		t.Errorf("Apply(): got %q, expected %q", got, merged)
+	}
+}
@@ -0,0 +1,567 @@
+// Package ntacl decodes and encodes Windows NT security descriptors stored in
+// extended attributes.
+//
+// CIFS mounts expose the security descriptor of a remote file as
+// system.cifs_acl in self-relative form. Samba keeps the descriptors of
+// shared files in security.NTACL, wrapped in an NDR encoded xattr_NTACL
+// structure; see ParseNTACL.
+package ntacl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/ivaxer/go-xattr"
+)
+
+// Attribute names holding NT security descriptors.
+const (
+	CIFSAttr  = "system.cifs_acl"
+	SambaAttr = "security.NTACL"
+)
+
+// Security descriptor control flags.
+const (
+	OwnerDefaulted       = 0x0001
+	GroupDefaulted       = 0x0002
+	DACLPresent          = 0x0004
+	DACLDefaulted        = 0x0008
+	SACLPresent          = 0x0010
+	SACLDefaulted        = 0x0020
+	DACLAutoInheritReq   = 0x0100
+	SACLAutoInheritReq   = 0x0200
+	DACLAutoInherited    = 0x0400
+	SACLAutoInherited    = 0x0800
+	DACLProtected        = 0x1000
+	SACLProtected        = 0x2000
+	RMControlValid       = 0x4000
+	SelfRelative         = 0x8000
+	securityDescriptorV1 = 1
+)
+
+// ACE types.
+const (
+	AccessAllowed               = 0x00
+	AccessDenied                = 0x01
+	SystemAudit                 = 0x02
+	SystemAlarm                 = 0x03
+	AccessAllowedCompound       = 0x04
+	AccessAllowedObject         = 0x05
+	AccessDeniedObject          = 0x06
+	SystemAuditObject           = 0x07
+	SystemAlarmObject           = 0x08
+	AccessAllowedCallback       = 0x09
+	AccessDeniedCallback        = 0x0a
+	AccessAllowedCallbackObject = 0x0b
+	AccessDeniedCallbackObject  = 0x0c
+	SystemAuditCallback         = 0x0d
+	SystemAlarmCallback         = 0x0e
+	SystemAuditCallbackObject   = 0x0f
+	SystemAlarmCallbackObject   = 0x10
+	SystemMandatoryLabel        = 0x11
+	SystemResourceAttribute     = 0x12
+	SystemScopedPolicyID        = 0x13
+)
+
+// ACE flags.
+const (
+	ObjectInherit      = 0x01
+	ContainerInherit   = 0x02
+	NoPropagateInherit = 0x04
+	InheritOnly        = 0x08
+	Inherited          = 0x10
+	SuccessfulAccess   = 0x40
+	FailedAccess       = 0x80
+)
+
+// Flags of object ACEs telling which GUIDs are present.
+const (
+	ObjectTypePresent          = 0x1
+	InheritedObjectTypePresent = 0x2
+)
+
+// ErrMalformed is returned when a security descriptor can't be decoded.
+var ErrMalformed = errors.New("ntacl: malformed security descriptor")
+
+// SID is a Windows security identifier.
+type SID struct {
+	Revision     uint8
+	Authority    uint64 // 48-bit identifier authority
+	SubAuthority []uint32
+}
+
+// ACE is an access control entry. ObjectType and InheritedObjectType are
+// only meaningful for object ACE types; ApplicationData holds whatever
+// follows the SID, such as the conditional expression of callback ACEs.
+type ACE struct {
+	Type                uint8
+	Flags               uint8
+	Mask                uint32
+	ObjectFlags         uint32
+	ObjectType          GUID
+	InheritedObjectType GUID
+	SID                 *SID
+	ApplicationData     []byte
+}
+
+// GUID is a Windows GUID in its on-disk (mixed-endian) byte order.
+type GUID [16]byte
+
+// ACL is an access control list.
+type ACL struct {
+	Revision uint8
+	ACEs     []ACE
+}
+
+// SecurityDescriptor is a decoded NT security descriptor. Nil fields are
+// absent from the descriptor.
+type SecurityDescriptor struct {
+	Revision uint8
+	Sbz1     uint8
+	Control  uint16
+	Owner    *SID
+	Group    *SID
+	SACL     *ACL
+	DACL     *ACL
+}
+
+// Parse decodes a self-relative security descriptor.
+func Parse(b []byte) (*SecurityDescriptor, error) {
+	if len(b) < 20 {
+		return nil, ErrMalformed
+	}
+	if b[0] != securityDescriptorV1 {
+		return nil, fmt.Errorf("ntacl: unsupported security descriptor revision %d", b[0])
+	}
+
+	sd := &SecurityDescriptor{
+		Revision: b[0],
+		Sbz1:     b[1],
+		Control:  binary.LittleEndian.Uint16(b[2:]),
+	}
+	if sd.Control&SelfRelative == 0 {
+		return nil, errors.New("ntacl: security descriptor is not self-relative")
+	}
+
+	offOwner := binary.LittleEndian.Uint32(b[4:])
+	offGroup := binary.LittleEndian.Uint32(b[8:])
+	offSACL := binary.LittleEndian.Uint32(b[12:])
+	offDACL := binary.LittleEndian.Uint32(b[16:])
+
+	var err error
+	if offOwner != 0 {
+		if sd.Owner, err = parseSIDAt(b, offOwner); err != nil {
+			return nil, err
+		}
+	}
+	if offGroup != 0 {
+		if sd.Group, err = parseSIDAt(b, offGroup); err != nil {
+			return nil, err
+		}
+	}
+	if sd.Control&SACLPresent != 0 && offSACL != 0 {
+		if sd.SACL, err = parseACLAt(b, offSACL); err != nil {
+			return nil, err
+		}
+	}
+	if sd.Control&DACLPresent != 0 && offDACL != 0 {
+		if sd.DACL, err = parseACLAt(b, offDACL); err != nil {
+			return nil, err
+		}
+	}
+	return sd, nil
+}
+
+func parseSIDAt(b []byte, off uint32) (*SID, error) {
+	if uint64(off) >= uint64(len(b)) {
+		return nil, ErrMalformed
+	}
+	sid, _, err := parseSID(b[off:])
+	return sid, err
+}
+
+// parseSID decodes a SID from the beginning of b and returns it along with
+// its encoded length.
+func parseSID(b []byte) (*SID, int, error) {
+	if len(b) < 8 {
+		return nil, 0, ErrMalformed
+	}
+	n := int(b[1])
+	size := 8 + 4*n
+	if len(b) < size {
+		return nil, 0, ErrMalformed
+	}
+
+	sid := &SID{Revision: b[0], SubAuthority: make([]uint32, n)}
+	for _, c := range b[2:8] {
+		sid.Authority = sid.Authority<<8 | uint64(c)
+	}
+	for i := range sid.SubAuthority {
+		sid.SubAuthority[i] = binary.LittleEndian.Uint32(b[8+4*i:])
+	}
+	return sid, size, nil
+}
+
+func parseACLAt(b []byte, off uint32) (*ACL, error) {
+	if uint64(off)+8 > uint64(len(b)) {
+		return nil, ErrMalformed
+	}
+	b = b[off:]
+
+	size := int(binary.LittleEndian.Uint16(b[2:]))
+	count := int(binary.LittleEndian.Uint16(b[4:]))
+	if size < 8 || size > len(b) {
+		return nil, ErrMalformed
+	}
+
+	acl := &ACL{Revision: b[0], ACEs: make([]ACE, 0, count)}
+	p := b[8:size]
+	for i := 0; i < count; i++ {
+		if len(p) < 4 {
+			return nil, ErrMalformed
+		}
+		aceSize := int(binary.LittleEndian.Uint16(p[2:]))
+		if aceSize < 8 || aceSize > len(p) {
+			return nil, ErrMalformed
+		}
+		ace, err := parseACE(p[:aceSize])
+		if err != nil {
+			return nil, err
+		}
+		acl.ACEs = append(acl.ACEs, ace)
+		p = p[aceSize:]
+	}
+	return acl, nil
+}
+
+func parseACE(b []byte) (ACE, error) {
+	ace := ACE{
+		Type:  b[0],
+		Flags: b[1],
+		Mask:  binary.LittleEndian.Uint32(b[4:]),
+	}
+
+	p := b[8:]
+	if isObjectType(ace.Type) {
+		if len(p) < 4 {
+			return ace, ErrMalformed
+		}
+		ace.ObjectFlags = binary.LittleEndian.Uint32(p)
+		p = p[4:]
+		if ace.ObjectFlags&ObjectTypePresent != 0 {
+			if len(p) < 16 {
+				return ace, ErrMalformed
+			}
+			copy(ace.ObjectType[:], p)
+			p = p[16:]
+		}
+		if ace.ObjectFlags&InheritedObjectTypePresent != 0 {
+			if len(p) < 16 {
+				return ace, ErrMalformed
+			}
+			copy(ace.InheritedObjectType[:], p)
+			p = p[16:]
+		}
+	}
+
+	sid, n, err := parseSID(p)
+	if err != nil {
+		return ace, err
+	}
+	ace.SID = sid
+	if len(p) > n {
+		ace.ApplicationData = append([]byte(nil), p[n:]...)
+	}
+	return ace, nil
+}
+
+func isObjectType(t uint8) bool {
+	switch t {
+	case AccessAllowedObject, AccessDeniedObject, SystemAuditObject, SystemAlarmObject,
+		AccessAllowedCallbackObject, AccessDeniedCallbackObject,
+		SystemAuditCallbackObject, SystemAlarmCallbackObject:
+		return true
+	}
+	return false
+}
+
+// Marshal encodes sd in self-relative form. The SelfRelative, DACLPresent
+// and SACLPresent control bits are set according to the descriptor contents.
+func (sd *SecurityDescriptor) Marshal() []byte {
+	control := sd.Control | SelfRelative
+	control &^= DACLPresent | SACLPresent
+	if sd.SACL != nil {
+		control |= SACLPresent
+	}
+	if sd.DACL != nil {
+		control |= DACLPresent
+	}
+
+	rev := sd.Revision
+	if rev == 0 {
+		rev = securityDescriptorV1
+	}
+
+	b := make([]byte, 20)
+	b[0] = rev
+	b[1] = sd.Sbz1
+	binary.LittleEndian.PutUint16(b[2:], control)
+
+	// Windows lays out the SACL, the DACL, the owner and the group in this
+	// order; follow it so that encoded descriptors compare byte for byte.
+	if sd.SACL != nil {
+		binary.LittleEndian.PutUint32(b[12:], uint32(len(b)))
+		b = append(b, sd.SACL.Marshal()...)
+	}
+	if sd.DACL != nil {
+		binary.LittleEndian.PutUint32(b[16:], uint32(len(b)))
+		b = append(b, sd.DACL.Marshal()...)
+	}
+	if sd.Owner != nil {
+		binary.LittleEndian.PutUint32(b[4:], uint32(len(b)))
+		b = append(b, sd.Owner.Marshal()...)
+	}
+	if sd.Group != nil {
+		binary.LittleEndian.PutUint32(b[8:], uint32(len(b)))
+		b = append(b, sd.Group.Marshal()...)
+	}
+	return b
+}
+
+// Marshal encodes the SID in its binary form.
+func (sid *SID) Marshal() []byte {
+	b := make([]byte, 8, 8+4*len(sid.SubAuthority))
+	b[0] = sid.Revision
+	if b[0] == 0 {
+		b[0] = 1
+	}
+	b[1] = uint8(len(sid.SubAuthority))
+	for i := 0; i < 6; i++ {
+		b[7-i] = uint8(sid.Authority >> (8 * uint(i)))
+	}
+	for _, sub := range sid.SubAuthority {
+		b = binary.LittleEndian.AppendUint32(b, sub)
+	}
+	return b
+}
+
+// Marshal encodes the ACL in its binary form.
+func (acl *ACL) Marshal() []byte {
+	rev := acl.Revision
+	if rev == 0 {
+		rev = 2
+	}
+
+	b := make([]byte, 8)
+	b[0] = rev
+	for i := range acl.ACEs {
+		b = append(b, acl.ACEs[i].Marshal()...)
+	}
+	binary.LittleEndian.PutUint16(b[2:], uint16(len(b)))
+	binary.LittleEndian.PutUint16(b[4:], uint16(len(acl.ACEs)))
+	return b
+}
+
+// Marshal encodes the ACE in its binary form.
+func (ace *ACE) Marshal() []byte {
+	b := make([]byte, 8)
+	b[0] = ace.Type
+	b[1] = ace.Flags
+	binary.LittleEndian.PutUint32(b[4:], ace.Mask)
+
+	if isObjectType(ace.Type) {
+		b = binary.LittleEndian.AppendUint32(b, ace.ObjectFlags)
+		if ace.ObjectFlags&ObjectTypePresent != 0 {
+			b = append(b, ace.ObjectType[:]...)
+		}
+		if ace.ObjectFlags&InheritedObjectTypePresent != 0 {
+			b = append(b, ace.InheritedObjectType[:]...)
+		}
+	}
+	if ace.SID != nil {
+		b = append(b, ace.SID.Marshal()...)
+	}
+	b = append(b, ace.ApplicationData...)
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+
+	binary.LittleEndian.PutUint16(b[2:], uint16(len(b)))
+	return b
+}
+
+// NTACL is the xattr_NTACL structure Samba stores in security.NTACL. The
+// hash fields are left empty by the versions that lack them: version 2 has
+// Hash only, version 3 HashType and Hash, version 4 all of them.
+type NTACL struct {
+	Version     uint16
+	HashType    uint16
+	Hash        []byte
+	Description string // what computed the hashes, such as "posix_acl"
+	Time        uint64 // NTTIME of the hash computation
+	SysACLHash  []byte
+	Descriptor  *SecurityDescriptor
+}
+
+// ParseNTACL decodes the xattr_NTACL structure Samba stores in
+// security.NTACL and returns the security descriptor it wraps. Versions 1
+// to 4 are supported.
+func ParseNTACL(b []byte) (*SecurityDescriptor, error) {
+	n, err := DecodeNTACL(b)
+	if err != nil {
+		return nil, err
+	}
+	return n.Descriptor, nil
+}
+
+// DecodeNTACL decodes the xattr_NTACL structure Samba stores in
+// security.NTACL, including the hashes of versions 2 to 4.
+func DecodeNTACL(b []byte) (*NTACL, error) {
+	if len(b) < 8 {
+		return nil, ErrMalformed
+	}
+
+	// uint16 version, uint16 union level, uint32 referent of the pointer
+	// to the version specific structure. NDR aligns each field to its
+	// size, but to at most 4 bytes, and encodes the targets of pointers
+	// after the structure holding them, in the order of the pointers.
+	n := &NTACL{Version: binary.LittleEndian.Uint16(b)}
+	off := 8
+	need := func(size int) bool { return off+size <= len(b) }
+	var descPtr uint32
+
+	switch n.Version {
+	case 1:
+		// The security descriptor itself follows.
+	case 2:
+		// security_descriptor_hash_v2: sd pointer, uint8 hash[16].
+		if !need(4 + 16) {
+			return nil, ErrMalformed
+		}
+		n.Hash = b[off+4 : off+4+16]
+		off += 4 + 16
+	case 3, 4:
+		// security_descriptor_hash_v3: sd pointer, uint16 hash_type,
+		// uint8 hash[64]. security_descriptor_hash_v4 continues with a
+		// description pointer, NTTIME time and uint8 sys_acl_hash[64].
+		if !need(4 + 2 + 64) {
+			return nil, ErrMalformed
+		}
+		n.HashType = binary.LittleEndian.Uint16(b[off+4:])
+		n.Hash = b[off+6 : off+6+64]
+		off += 4 + 2 + 64
+		if n.Version == 3 {
+			break
+		}
+		off = align(off, 4)
+		if !need(4 + 8 + 64) {
+			return nil, ErrMalformed
+		}
+		descPtr = binary.LittleEndian.Uint32(b[off:])
+		n.Time = binary.LittleEndian.Uint64(b[off+4:])
+		n.SysACLHash = b[off+12 : off+12+64]
+		off += 4 + 8 + 64
+	default:
+		return nil, fmt.Errorf("ntacl: unsupported NTACL version %d", n.Version)
+	}
+
+	off = align(off, 4)
+	if off >= len(b) {
+		return nil, ErrMalformed
+	}
+	sd, err := Parse(b[off:])
+	if err != nil {
+		return nil, err
+	}
+	n.Descriptor = sd
+
+	if descPtr != 0 {
+		// A conformant varying string: uint32 size, offset and length,
+		// then length bytes including the terminating NUL.
+		off = align(off+sdLen(b[off:], sd), 4)
+		if !need(12) {
+			return nil, ErrMalformed
+		}
+		length := int(binary.LittleEndian.Uint32(b[off+8:]))
+		off += 12
+		if length < 0 || !need(length) {
+			return nil, ErrMalformed
+		}
+		desc := b[off : off+length]
+		if i := bytes.IndexByte(desc, 0); i >= 0 {
+			desc = desc[:i]
+		}
+		n.Description = string(desc)
+	}
+	return n, nil
+}
+
+// sdLen returns the length of sd, decoded from the start of b: the end of
+// its last part.
+func sdLen(b []byte, sd *SecurityDescriptor) int {
+	end := 20
+	extend := func(field, size int) {
+		if off := int(binary.LittleEndian.Uint32(b[field:])); off+size > end {
+			end = off + size
+		}
+	}
+	if sd.Owner != nil {
+		extend(4, 8+4*len(sd.Owner.SubAuthority))
+	}
+	if sd.Group != nil {
+		extend(8, 8+4*len(sd.Group.SubAuthority))
+	}
+	for field, acl := range map[int]*ACL{12: sd.SACL, 16: sd.DACL} {
+		if acl != nil {
+			off := binary.LittleEndian.Uint32(b[field:])
+			extend(field, int(binary.LittleEndian.Uint16(b[off+2:])))
+		}
+	}
+	return end
+}
+
+func align(off, n int) int {
+	return (off + n - 1) &^ (n - 1)
+}
+
+// Get retrieves and decodes the security descriptor of path. It tries
+// system.cifs_acl first and falls back to Samba's security.NTACL.
+func Get(path string) (*SecurityDescriptor, error) {
+	b, err := xattr.Get(path, CIFSAttr)
+	if err == nil {
+		return decode(path, b, Parse)
+	}
+	if !xattr.IsNotExist(err) && !isUnsupported(err) {
+		return nil, err
+	}
+
+	b, err = xattr.Get(path, SambaAttr)
+	if err != nil {
+		return nil, err
+	}
+	return decode(path, b, ParseNTACL)
+}
+
+func decode(path string, b []byte, parse func([]byte) (*SecurityDescriptor, error)) (*SecurityDescriptor, error) {
+	sd, err := parse(b)
+	if err != nil {
+		return nil, &os.PathError{Op: "getxattr", Path: path, Err: err}
+	}
+	return sd, nil
+}
+
+func isUnsupported(err error) bool {
+	if e, ok := err.(*os.PathError); ok {
+		err = e.Err
+	}
+	return err == syscall.ENOTSUP
+}
+
+// Set stores sd as the system.cifs_acl of path on a CIFS mount.
+func Set(path string, sd *SecurityDescriptor) error {
+	return xattr.Set(path, CIFSAttr, sd.Marshal())
+}
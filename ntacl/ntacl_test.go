@@ -0,0 +1,166 @@
+package ntacl
+
+import (
+	"bytes"
+	"testing"
+)
+
+func mustSID(t *testing.T, s string) *SID {
+	sid, err := ParseSID(s)
+	if err != nil {
+		t.Fatalf("ParseSID(%q) failed: %v", s, err)
+	}
+	return sid
+}
+
+func testDescriptor(t *testing.T) *SecurityDescriptor {
+	return &SecurityDescriptor{
+		Control: DACLProtected | DACLAutoInherited,
+		Owner:   mustSID(t, "S-1-5-21-1004336348-1177238915-682003330-512"),
+		Group:   mustSID(t, "S-1-5-32-544"),
+		DACL: &ACL{ACEs: []ACE{
+			{Type: AccessAllowed, Flags: ObjectInherit | ContainerInherit, Mask: 0x001f01ff, SID: mustSID(t, "S-1-5-18")},
+			{Type: AccessDenied, Mask: 0x00120116, SID: mustSID(t, "S-1-1-0")},
+			{Type: AccessAllowedObject, Mask: 0x100, ObjectFlags: ObjectTypePresent,
+				ObjectType: GUID{0x79, 0x3a, 0xa6, 0xbf, 0x3f, 0x6d, 0xd0, 0x11, 0x89, 0x6a, 0x00, 0x60, 0x97, 0x00, 0x00, 0x00},
+				SID:        mustSID(t, "S-1-5-11")},
+		}},
+	}
+}
+
+func TestSIDString(t *testing.T) {
+	for _, s := range []string{"S-1-5-18", "S-1-5-21-1004336348-1177238915-682003330-512", "S-1-0x010000000000-7"} {
+		if got := mustSID(t, s).String(); got != s {
+			t.Errorf("ParseSID(%q).String() = %q", s, got)
+		}
+	}
+
+	for _, s := range []string{"", "S-1", "X-1-5", "S-1-5-abc"} {
+		if _, err := ParseSID(s); err == nil {
+			t.Errorf("ParseSID(%q): expected error", s)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	b := testDescriptor(t).Marshal()
+
+	sd, err := Parse(b)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if got := sd.Marshal(); !bytes.Equal(got, b) {
+		t.Errorf("Marshal(Parse(b)) = %x, expected %x", got, b)
+	}
+
+	expected := "O:S-1-5-21-1004336348-1177238915-682003330-512G:BAD:PAI" +
+		"(A;OICI;FA;;;SY)(D;;FW;;;WD)(OA;;0x100;bfa63a79-6d3f-11d0-896a-006097000000;;AU)"
+	if got := sd.String(); got != expected {
+		t.Errorf("String() = %q, expected %q", got, expected)
+	}
+}
+
+func TestParseNTACL(t *testing.T) {
+	sd := testDescriptor(t).Marshal()
+
+	v1 := append([]byte{1, 0, 1, 0, 0, 0, 2, 0}, sd...)
+
+	v3 := []byte{3, 0, 3, 0, 0, 0, 2, 0, 4, 0, 2, 0, 1, 0}
+	v3 = append(v3, make([]byte, 64+2)...)
+	v3 = append(v3, sd...)
+
+	for _, b := range [][]byte{v1, v3, ntaclV4(sd)} {
+		got, err := ParseNTACL(b)
+		if err != nil {
+			t.Errorf("ParseNTACL(v%d) failed: %v", b[0], err)
+			continue
+		}
+		if !bytes.Equal(got.Marshal(), sd) {
+			t.Errorf("ParseNTACL(v%d): descriptor mismatch", b[0])
+		}
+	}
+}
+
+// ntaclV4 lays out a version 4 xattr_NTACL holding sd as Samba's NDR
+// encoder does for librpc/idl/xattr.idl, with a SHA-256 hash.
+func ntaclV4(sd []byte) []byte {
+	b := []byte{
+		4, 0, // version
+		4, 0, // union level
+		0x00, 0x00, 0x02, 0x00, // referent of the hash_v4 pointer
+		0x04, 0x00, 0x02, 0x00, // referent of the sd pointer
+		1, 0, // hash_type
+	}
+	for i := 0; i < 64; i++ {
+		b = append(b, ntaclHash(i))
+	}
+	b = append(b, 0, 0)                                           // padding to 80
+	b = append(b, 0x08, 0x00, 0x02, 0x00)                         // referent of the description pointer
+	b = append(b, 0xe5, 0xd4, 0xc3, 0xb2, 0xa1, 0xc8, 0xd9, 0x01) // time, at 84
+	for i := 0; i < 64; i++ {
+		b = append(b, ntaclHash(i)^0xff)
+	}
+	b = append(b, sd...) // at 156
+	// Conformant varying string: size, offset, length.
+	b = append(b, 10, 0, 0, 0, 0, 0, 0, 0, 10, 0, 0, 0)
+	return append(b, "posix_acl\x00"...)
+}
+
+// ntaclHash returns byte i of the hash in ntaclV4, whose SHA-256 hash
+// fills the first 32 bytes only.
+func ntaclHash(i int) byte {
+	if i < 32 {
+		return byte(i + 1)
+	}
+	return 0
+}
+
+func TestDecodeNTACL(t *testing.T) {
+	sd := testDescriptor(t).Marshal()
+	n, err := DecodeNTACL(ntaclV4(sd))
+	if err != nil {
+		t.Fatalf("DecodeNTACL() failed: %v", err)
+	}
+
+	hash := make([]byte, 64)
+	sysHash := make([]byte, 64)
+	for i := range hash {
+		hash[i] = ntaclHash(i)
+		sysHash[i] = ntaclHash(i) ^ 0xff
+	}
+	if n.Version != 4 || n.HashType != 1 {
+		t.Errorf("DecodeNTACL(): got version %d, hash type %d", n.Version, n.HashType)
+	}
+	if !bytes.Equal(n.Hash, hash) {
+		t.Errorf("DecodeNTACL(): got hash %x, expected %x", n.Hash, hash)
+	}
+	if n.Description != "posix_acl" {
+		t.Errorf("DecodeNTACL(): got description %q, expected %q", n.Description, "posix_acl")
+	}
+	if n.Time != 0x01d9c8a1b2c3d4e5 {
+		t.Errorf("DecodeNTACL(): got time %#x", n.Time)
+	}
+	if !bytes.Equal(n.SysACLHash, sysHash) {
+		t.Errorf("DecodeNTACL(): got sys_acl_hash %x, expected %x", n.SysACLHash, sysHash)
+	}
+	if n.Descriptor == nil || !bytes.Equal(n.Descriptor.Marshal(), sd) {
+		t.Error("DecodeNTACL(): descriptor mismatch")
+	}
+
+	b := ntaclV4(sd)
+	for i := range b {
+		if _, err := DecodeNTACL(b[:i]); err == nil {
+			t.Errorf("DecodeNTACL(b[:%d]): expected error", i)
+		}
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	b := testDescriptor(t).Marshal()
+
+	for _, n := range []int{0, 19, 40, len(b) - 1} {
+		if _, err := Parse(b[:n]); err == nil {
+			t.Errorf("Parse(b[:%d]): expected error", n)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package ntacl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sidAliases maps well-known SIDs to their SDDL abbreviations.
+var sidAliases = map[string]string{
+	"S-1-1-0":      "WD",
+	"S-1-3-0":      "CO",
+	"S-1-3-1":      "CG",
+	"S-1-3-4":      "OW",
+	"S-1-5-2":      "NU",
+	"S-1-5-4":      "IU",
+	"S-1-5-6":      "SU",
+	"S-1-5-7":      "AN",
+	"S-1-5-9":      "ED",
+	"S-1-5-10":     "PS",
+	"S-1-5-11":     "AU",
+	"S-1-5-12":     "RC",
+	"S-1-5-18":     "SY",
+	"S-1-5-19":     "LS",
+	"S-1-5-20":     "NS",
+	"S-1-5-32-544": "BA",
+	"S-1-5-32-545": "BU",
+	"S-1-5-32-546": "BG",
+	"S-1-5-32-547": "PU",
+	"S-1-5-32-548": "AO",
+	"S-1-5-32-549": "SO",
+	"S-1-5-32-550": "PO",
+	"S-1-5-32-551": "BO",
+	"S-1-5-32-552": "RE",
+	"S-1-5-32-554": "RU",
+	"S-1-5-32-555": "RD",
+	"S-1-5-32-556": "NO",
+	"S-1-16-4096":  "LW",
+	"S-1-16-8192":  "ME",
+	"S-1-16-12288": "HI",
+	"S-1-16-16384": "SI",
+}
+
+var aceTypeNames = map[uint8]string{
+	AccessAllowed:               "A",
+	AccessDenied:                "D",
+	SystemAudit:                 "AU",
+	SystemAlarm:                 "AL",
+	AccessAllowedObject:         "OA",
+	AccessDeniedObject:          "OD",
+	SystemAuditObject:           "OU",
+	SystemAlarmObject:           "OL",
+	AccessAllowedCallback:       "XA",
+	AccessDeniedCallback:        "XD",
+	AccessAllowedCallbackObject: "ZA",
+	SystemAuditCallback:         "XU",
+	SystemMandatoryLabel:        "ML",
+	SystemResourceAttribute:     "RA",
+	SystemScopedPolicyID:        "SP",
+}
+
+var aceFlagNames = []struct {
+	flag uint8
+	name string
+}{
+	{ObjectInherit, "OI"},
+	{ContainerInherit, "CI"},
+	{NoPropagateInherit, "NP"},
+	{InheritOnly, "IO"},
+	{Inherited, "ID"},
+	{SuccessfulAccess, "SA"},
+	{FailedAccess, "FA"},
+}
+
+// maskAliases maps whole access masks to SDDL rights strings. Masks
+// without an alias are rendered in hex.
+var maskAliases = map[uint32]string{
+	0x10000000: "GA",
+	0x80000000: "GR",
+	0x40000000: "GW",
+	0x20000000: "GX",
+	0x001f01ff: "FA",
+	0x00120089: "FR",
+	0x00120116: "FW",
+	0x001200a0: "FX",
+}
+
+// String returns the SID in its S-R-I-S-S... form.
+func (sid *SID) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "S-%d-", sid.Revision)
+	if sid.Authority >= 1<<32 {
+		fmt.Fprintf(&b, "0x%012X", sid.Authority)
+	} else {
+		b.WriteString(strconv.FormatUint(sid.Authority, 10))
+	}
+	for _, sub := range sid.SubAuthority {
+		b.WriteByte('-')
+		b.WriteString(strconv.FormatUint(uint64(sub), 10))
+	}
+	return b.String()
+}
+
+// ParseSID parses a SID in its S-R-I-S-S... form.
+func ParseSID(s string) (*SID, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) < 3 || parts[0] != "S" {
+		return nil, fmt.Errorf("ntacl: invalid SID %q", s)
+	}
+
+	rev, err := strconv.ParseUint(parts[1], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("ntacl: invalid SID %q", s)
+	}
+	auth, err := strconv.ParseUint(parts[2], 0, 48)
+	if err != nil {
+		return nil, fmt.Errorf("ntacl: invalid SID %q", s)
+	}
+
+	sid := &SID{Revision: uint8(rev), Authority: auth}
+	for _, p := range parts[3:] {
+		sub, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("ntacl: invalid SID %q", s)
+		}
+		sid.SubAuthority = append(sid.SubAuthority, uint32(sub))
+	}
+	return sid, nil
+}
+
+func (sid *SID) sddl() string {
+	s := sid.String()
+	if alias, ok := sidAliases[s]; ok {
+		return alias
+	}
+	return s
+}
+
+// String returns the GUID in its canonical textual form.
+func (g GUID) String() string {
+	return fmt.Sprintf("%02x%02x%02x%02x-%02x%02x-%02x%02x-%02x%02x-%02x%02x%02x%02x%02x%02x",
+		g[3], g[2], g[1], g[0], g[5], g[4], g[7], g[6],
+		g[8], g[9], g[10], g[11], g[12], g[13], g[14], g[15])
+}
+
+// String renders the ACE as an SDDL ace string.
+func (ace *ACE) String() string {
+	typ, ok := aceTypeNames[ace.Type]
+	if !ok {
+		typ = fmt.Sprintf("0x%x", ace.Type)
+	}
+
+	var flags strings.Builder
+	for _, f := range aceFlagNames {
+		if ace.Flags&f.flag != 0 {
+			flags.WriteString(f.name)
+		}
+	}
+
+	rights, ok := maskAliases[ace.Mask]
+	if !ok {
+		rights = fmt.Sprintf("0x%x", ace.Mask)
+	}
+
+	var objType, inhType string
+	if isObjectType(ace.Type) {
+		if ace.ObjectFlags&ObjectTypePresent != 0 {
+			objType = ace.ObjectType.String()
+		}
+		if ace.ObjectFlags&InheritedObjectTypePresent != 0 {
+			inhType = ace.InheritedObjectType.String()
+		}
+	}
+
+	var sid string
+	if ace.SID != nil {
+		sid = ace.SID.sddl()
+	}
+	return fmt.Sprintf("(%s;%s;%s;%s;%s;%s)", typ, flags.String(), rights, objType, inhType, sid)
+}
+
+// String renders the security descriptor in Security Descriptor
+// Definition Language.
+func (sd *SecurityDescriptor) String() string {
+	var b strings.Builder
+	if sd.Owner != nil {
+		b.WriteString("O:" + sd.Owner.sddl())
+	}
+	if sd.Group != nil {
+		b.WriteString("G:" + sd.Group.sddl())
+	}
+	if sd.DACL != nil {
+		b.WriteString("D:")
+		writeACL(&b, sd.DACL, sd.Control&DACLProtected != 0,
+			sd.Control&DACLAutoInheritReq != 0, sd.Control&DACLAutoInherited != 0)
+	}
+	if sd.SACL != nil {
+		b.WriteString("S:")
+		writeACL(&b, sd.SACL, sd.Control&SACLProtected != 0,
+			sd.Control&SACLAutoInheritReq != 0, sd.Control&SACLAutoInherited != 0)
+	}
+	return b.String()
+}
+
+func writeACL(b *strings.Builder, acl *ACL, protected, autoInheritReq, autoInherited bool) {
+	if protected {
+		b.WriteString("P")
+	}
+	if autoInheritReq {
+		b.WriteString("AR")
+	}
+	if autoInherited {
+		b.WriteString("AI")
+	}
+	for i := range acl.ACEs {
+		b.WriteString(acl.ACEs[i].String())
+	}
+}
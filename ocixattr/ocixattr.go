@@ -0,0 +1,191 @@
+// Package ocixattr normalizes extended attributes for reproducible,
+// portable container image layers.
+//
+// Attributes that only make sense on the build host are stripped: SELinux
+// labels are assigned by the runtime's policy, trusted.* needs privileges
+// on extraction and is mostly overlayfs bookkeeping, and com.apple.* is
+// Finder and quarantine metadata. Namespaced (revision 3) file
+// capabilities are rewritten as revision 2, which every runtime can apply
+// and which doesn't tie the layer to the builder's user namespace.
+//
+// In tar layers attributes are PAX records named SCHILY.xattr.<name>;
+// NormalizeHeader applies the normalization to a tar.Header before it is
+// written and Records returns the records in a deterministic order for
+// writers that need one.
+package ocixattr
+
+import (
+	"archive/tar"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ivaxer/go-xattr/internal/vfscap"
+)
+
+// PAXPrefix is the prefix of PAX records holding extended attributes.
+const PAXPrefix = "SCHILY.xattr."
+
+// DefaultStrip lists the attributes stripped by default.
+var DefaultStrip = []string{"security.selinux", "trusted.", "com.apple."}
+
+// Options configure the normalization. A nil *Options means the defaults.
+type Options struct {
+	// Strip lists the attributes to drop. Entries ending in '.' match
+	// every attribute with that prefix, others match one name. Nil
+	// means DefaultStrip.
+	Strip []string
+
+	// KeepCapabilityV3 leaves revision 3 capabilities alone.
+	KeepCapabilityV3 bool
+}
+
+// Action is what the normalization did to an attribute.
+type Action int
+
+// Actions.
+const (
+	Stripped Action = iota
+	Converted
+)
+
+func (a Action) String() string {
+	switch a {
+	case Stripped:
+		return "stripped"
+	case Converted:
+		return "converted"
+	}
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+// Change reports a modified attribute.
+type Change struct {
+	Name   string
+	Action Action
+	Reason string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s %s: %s", c.Action, c.Name, c.Reason)
+}
+
+// Normalize returns the normalized copy of attrs and the changes made, in
+// the order of the attribute names. attrs is not modified.
+func Normalize(attrs map[string][]byte, opts *Options) (map[string][]byte, []Change, error) {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.Strip == nil {
+		o.Strip = DefaultStrip
+	}
+
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := make(map[string][]byte, len(attrs))
+	var changes []Change
+	for _, name := range names {
+		value := attrs[name]
+		if pattern, ok := match(o.Strip, name); ok {
+			changes = append(changes, Change{name, Stripped, "matches " + pattern})
+			continue
+		}
+		if name == vfscap.Attr && !o.KeepCapabilityV3 {
+			v, change, err := convertCapability(value)
+			if err != nil {
+				return nil, nil, err
+			}
+			if change != "" {
+				changes = append(changes, Change{name, Converted, change})
+			}
+			value = v
+		}
+		out[name] = value
+	}
+	return out, changes, nil
+}
+
+func match(patterns []string, name string) (string, bool) {
+	for _, p := range patterns {
+		if p == name || strings.HasSuffix(p, ".") && strings.HasPrefix(name, p) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// convertCapability rewrites revision 3 capabilities as revision 2 and
+// describes the change, if any.
+func convertCapability(value []byte) ([]byte, string, error) {
+	c, err := vfscap.Parse(value)
+	if err != nil {
+		return nil, "", err
+	}
+	if c.Revision != vfscap.Revision3 {
+		return value, "", nil
+	}
+
+	rootID := c.RootID
+	c.Revision, c.RootID = vfscap.Revision2, 0
+	b, err := c.Marshal()
+	if err != nil {
+		return nil, "", err
+	}
+	return b, fmt.Sprintf("capability revision 3 (rootid %d) to revision 2", rootID), nil
+}
+
+// Record is a PAX record.
+type Record struct {
+	Key   string
+	Value string
+}
+
+// Records returns the PAX records carrying attrs, sorted by key.
+func Records(attrs map[string][]byte) []Record {
+	records := make([]Record, 0, len(attrs))
+	for name, value := range attrs {
+		records = append(records, Record{PAXPrefix + name, string(value)})
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Key < records[j].Key })
+	return records
+}
+
+// NormalizeHeader normalizes the attributes of hdr, taken from both
+// PAXRecords and the deprecated Xattrs field, and stores the result in
+// PAXRecords. archive/tar writes PAX records sorted by key, so headers
+// with equal attributes produce identical output.
+func NormalizeHeader(hdr *tar.Header, opts *Options) ([]Change, error) {
+	attrs := make(map[string][]byte)
+	for key, value := range hdr.PAXRecords {
+		if strings.HasPrefix(key, PAXPrefix) {
+			attrs[key[len(PAXPrefix):]] = []byte(value)
+		}
+	}
+	for name, value := range hdr.Xattrs {
+		attrs[name] = []byte(value)
+	}
+
+	normalized, changes, err := Normalize(attrs, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for key := range hdr.PAXRecords {
+		if strings.HasPrefix(key, PAXPrefix) {
+			delete(hdr.PAXRecords, key)
+		}
+	}
+	hdr.Xattrs = nil
+	if len(normalized) > 0 && hdr.PAXRecords == nil {
+		hdr.PAXRecords = make(map[string]string, len(normalized))
+	}
+	for _, r := range Records(normalized) {
+		hdr.PAXRecords[r.Key] = r.Value
+	}
+	return changes, nil
+}
@@ -0,0 +1,121 @@
+package ocixattr
+
+import (
+	"archive/tar"
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/ivaxer/go-xattr/internal/vfscap"
+)
+
+func capability(revision int, rootID uint32) []byte {
+	c := &vfscap.Capability{Revision: revision, Effective: true, Permitted: 1 << 13, RootID: rootID}
+	b, err := c.Marshal()
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestNormalize(t *testing.T) {
+	attrs := map[string][]byte{
+		"security.selinux":       []byte("unconfined_u:object_r:user_home_t:s0\x00"),
+		"trusted.overlay.opaque": []byte("y"),
+		"com.apple.quarantine":   []byte("0083;5f3c1b7b;Safari;"),
+		"security.capability":    capability(vfscap.Revision3, 100000),
+		"user.mime_type":         []byte("text/plain"),
+	}
+
+	got, changes, err := Normalize(attrs, nil)
+	if err != nil {
+		t.Fatalf("Normalize() failed: %v", err)
+	}
+	expected := map[string][]byte{
+		"security.capability": capability(vfscap.Revision2, 0),
+		"user.mime_type":      []byte("text/plain"),
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Normalize(): got %q, expected %q", got, expected)
+	}
+	if len(attrs) != 5 {
+		t.Errorf("Normalize(): modified its argument")
+	}
+
+	expectedChanges := []Change{
+		{"com.apple.quarantine", Stripped, "matches com.apple."},
+		{"security.capability", Converted, "capability revision 3 (rootid 100000) to revision 2"},
+		{"security.selinux", Stripped, "matches security.selinux"},
+		{"trusted.overlay.opaque", Stripped, "matches trusted."},
+	}
+	if !reflect.DeepEqual(changes, expectedChanges) {
+		t.Errorf("Normalize(): got changes %v, expected %v", changes, expectedChanges)
+	}
+
+	got, changes, err = Normalize(attrs, &Options{Strip: []string{"user."}, KeepCapabilityV3: true})
+	if err != nil {
+		t.Fatalf("Normalize() failed: %v", err)
+	}
+	if len(got) != 4 || len(changes) != 1 || !bytes.Equal(got["security.capability"], attrs["security.capability"]) {
+		t.Errorf("Normalize(custom): got %q, changes %v", got, changes)
+	}
+
+	if _, _, err := Normalize(map[string][]byte{"security.capability": {1}}, nil); err == nil {
+		t.Error("Normalize(): expected error on malformed capability")
+	}
+}
+
+func TestNormalizeHeader(t *testing.T) {
+	write := func(hdr *tar.Header) []byte {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("WriteHeader() failed: %v", err)
+		}
+		tw.Close()
+		return buf.Bytes()
+	}
+
+	a := &tar.Header{Name: "bin/ping", Mode: 0755, Typeflag: tar.TypeReg, Format: tar.FormatPAX,
+		PAXRecords: map[string]string{
+			"SCHILY.xattr.security.selinux":    "system_u:object_r:ping_exec_t:s0",
+			"SCHILY.xattr.security.capability": string(capability(vfscap.Revision3, 0)),
+			"SCHILY.xattr.user.b":              "2",
+		},
+	}
+	b := &tar.Header{Name: "bin/ping", Mode: 0755, Typeflag: tar.TypeReg, Format: tar.FormatPAX,
+		Xattrs: map[string]string{
+			"user.b":              "2",
+			"security.capability": string(capability(vfscap.Revision2, 0)),
+		},
+	}
+
+	changes, err := NormalizeHeader(a, nil)
+	if err != nil {
+		t.Fatalf("NormalizeHeader() failed: %v", err)
+	}
+	if len(changes) != 2 {
+		t.Errorf("NormalizeHeader(): got changes %v", changes)
+	}
+	if _, err := NormalizeHeader(b, nil); err != nil {
+		t.Fatalf("NormalizeHeader() failed: %v", err)
+	}
+	if b.Xattrs != nil {
+		t.Errorf("NormalizeHeader(): Xattrs not cleared")
+	}
+	if !bytes.Equal(write(a), write(b)) {
+		t.Errorf("NormalizeHeader(): equal attributes produced different headers")
+	}
+}
+
+func TestRecords(t *testing.T) {
+	got := Records(map[string][]byte{"user.z": []byte("1"), "user.a": []byte("2"), "security.ima": {3}})
+	expected := []Record{
+		{"SCHILY.xattr.security.ima", "\x03"},
+		{"SCHILY.xattr.user.a", "2"},
+		{"SCHILY.xattr.user.z", "1"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Records(): got %q, expected %q", got, expected)
+	}
+}
@@ -0,0 +1,255 @@
+// Package policy restricts which extended attributes a program may write,
+// for services sharing files.
+//
+// A Policy is an ordered list of rules loaded from JSON:
+//
+//	{
+//	  "default": "deny",
+//	  "rules": [
+//	    {"effect": "deny", "names": ["user.owner"]},
+//	    {"effect": "allow", "ops": ["set"], "namespaces": ["user."],
+//	     "paths": ["/srv/cache"], "max_size": 4096},
+//	    {"effect": "allow", "ops": ["remove"], "names": ["user.cache.*"]}
+//	  ]
+//	}
+//
+// The first rule matching a request decides it; requests no rule matches
+// get the default effect, which is deny when unset. Policy.Set and
+// Policy.Remove check the request and then call xattr.Set and
+// xattr.Remove; denied requests fail with a *DeniedError.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ivaxer/go-xattr"
+)
+
+// Effects.
+const (
+	Allow = "allow"
+	Deny  = "deny"
+)
+
+// Operations.
+const (
+	OpSet    = "set"
+	OpRemove = "remove"
+)
+
+// Rule allows or denies the requests matching all of its conditions.
+// Empty conditions match everything.
+type Rule struct {
+	Effect string `json:"effect"`
+
+	// Ops lists the operations, OpSet or OpRemove.
+	Ops []string `json:"ops,omitempty"`
+
+	// Namespaces lists name prefixes, e.g. "user.".
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Names lists name patterns in the syntax of path.Match, e.g.
+	// "user.cache.*".
+	Names []string `json:"names,omitempty"`
+
+	// Paths lists the directories the rule applies to, including
+	// everything below them. Validate makes relative paths absolute with
+	// the working directory and resolves symbolic links.
+	Paths []string `json:"paths,omitempty"`
+
+	// MaxSize, if positive, limits the values an allow rule lets
+	// through: larger values are denied by the rule.
+	MaxSize int `json:"max_size,omitempty"`
+}
+
+// Policy is an ordered list of rules with a default effect. Policies not
+// obtained from Load or LoadFile must be validated with Validate before
+// use.
+type Policy struct {
+	Default string `json:"default,omitempty"`
+	Rules   []Rule `json:"rules"`
+}
+
+// Load reads a policy in JSON and validates it.
+func Load(r io.Reader) (*Policy, error) {
+	p := new(Policy)
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(p); err != nil {
+		return nil, fmt.Errorf("policy: invalid policy: %v", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// LoadFile reads a policy from the named JSON file.
+func LoadFile(name string) (*Policy, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// Validate checks the effects, operations and name patterns of p and
+// normalizes the paths of its rules.
+func (p *Policy) Validate() error {
+	if p.Default != "" && p.Default != Allow && p.Default != Deny {
+		return fmt.Errorf("policy: invalid default effect %q", p.Default)
+	}
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if r.Effect != Allow && r.Effect != Deny {
+			return fmt.Errorf("policy: rule %d: invalid effect %q", i, r.Effect)
+		}
+		for _, op := range r.Ops {
+			if op != OpSet && op != OpRemove {
+				return fmt.Errorf("policy: rule %d: invalid op %q", i, op)
+			}
+		}
+		for _, pattern := range r.Names {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("policy: rule %d: invalid name pattern %q", i, pattern)
+			}
+		}
+		for j, dir := range r.Paths {
+			abs, err := resolve(dir)
+			if err != nil {
+				return fmt.Errorf("policy: rule %d: %v", i, err)
+			}
+			r.Paths[j] = abs
+		}
+	}
+	return nil
+}
+
+// DeniedError is returned for requests the policy denies.
+type DeniedError struct {
+	Op   string
+	Path string
+	Name string
+
+	// Rule is the index of the deciding rule, or -1 for the default.
+	Rule   int
+	Reason string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("policy: %s %s on %s denied: %s", e.Op, e.Name, e.Path, e.Reason)
+}
+
+// IsDenied reports whether err is a *DeniedError.
+func IsDenied(err error) bool {
+	_, ok := err.(*DeniedError)
+	return ok
+}
+
+// resolve returns the absolute path of file with symbolic links resolved,
+// so links can't be used to reach files outside the paths of a rule. If
+// file doesn't exist, its parent directory is resolved instead.
+func resolve(file string) (string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err == nil {
+		return resolved, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(abs))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return abs, nil
+		}
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(abs)), nil
+}
+
+// Check decides a request to apply op to the attribute name of file with
+// a value of size bytes, and returns a *DeniedError if it is denied.
+// Symbolic links in file are resolved before matching the paths of the
+// rules.
+func (p *Policy) Check(op, file, name string, size int) error {
+	abs, err := resolve(file)
+	if err != nil {
+		return err
+	}
+
+	for i, r := range p.Rules {
+		if !r.match(op, abs, name) {
+			continue
+		}
+		if r.Effect == Deny {
+			return &DeniedError{op, file, name, i, fmt.Sprintf("denied by rule %d", i)}
+		}
+		if op == OpSet && r.MaxSize > 0 && size > r.MaxSize {
+			return &DeniedError{op, file, name, i, fmt.Sprintf("value of %d bytes exceeds %d allowed by rule %d", size, r.MaxSize, i)}
+		}
+		return nil
+	}
+
+	if p.Default != Allow {
+		return &DeniedError{op, file, name, -1, "no rule allows it"}
+	}
+	return nil
+}
+
+func (r *Rule) match(op, file, name string) bool {
+	return matchAny(r.Ops, func(o string) bool { return o == op }) &&
+		matchAny(r.Namespaces, func(ns string) bool { return strings.HasPrefix(name, ns) }) &&
+		matchAny(r.Names, func(pattern string) bool {
+			ok, _ := path.Match(pattern, name)
+			return ok
+		}) &&
+		matchAny(r.Paths, func(dir string) bool { return under(file, dir) })
+}
+
+// matchAny reports whether f holds for any of list, or list is empty.
+func matchAny(list []string, f func(string) bool) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, s := range list {
+		if f(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// under reports whether file is dir or below it.
+func under(file, dir string) bool {
+	if file == dir || dir == string(filepath.Separator) {
+		return true
+	}
+	return strings.HasPrefix(file, dir+string(filepath.Separator))
+}
+
+// Set sets the attribute name of path to value if the policy allows it.
+func (p *Policy) Set(path, name string, value []byte) error {
+	if err := p.Check(OpSet, path, name, len(value)); err != nil {
+		return err
+	}
+	return xattr.Set(path, name, value)
+}
+
+// Remove removes the attribute name of path if the policy allows it.
+func (p *Policy) Remove(path, name string) error {
+	if err := p.Check(OpRemove, path, name, 0); err != nil {
+		return err
+	}
+	return xattr.Remove(path, name)
+}
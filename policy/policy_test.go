@@ -0,0 +1,127 @@
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ivaxer/go-xattr"
+	"github.com/ivaxer/go-xattr/xattrtest"
+)
+
+const config = `{
+	"rules": [
+		{"effect": "deny", "names": ["user.owner"]},
+		{"effect": "allow", "ops": ["set"], "namespaces": ["user."], "paths": ["/srv/cache"], "max_size": 8},
+		{"effect": "allow", "ops": ["remove"], "names": ["user.cache.*"]}
+	]
+}`
+
+func TestCheck(t *testing.T) {
+	p, err := Load(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	tests := []struct {
+		op, path, name string
+		size           int
+		rule           int // -2 when allowed
+	}{
+		{OpSet, "/srv/cache/a", "user.x", 8, -2},
+		{OpSet, "/srv/cache", "user.x", 1, -2},
+		{OpSet, "/srv/cache/a", "user.x", 9, 1},
+		{OpSet, "/srv/cache/a", "user.owner", 1, 0},
+		{OpSet, "/srv/cachex/a", "user.x", 1, -1},
+		{OpSet, "/srv/cache/a", "trusted.x", 1, -1},
+		{OpRemove, "/srv/cache/a", "user.x", 0, -1},
+		{OpRemove, "/anywhere", "user.cache.hint", 0, -2},
+	}
+	for _, test := range tests {
+		err := p.Check(test.op, test.path, test.name, test.size)
+		if test.rule == -2 {
+			if err != nil {
+				t.Errorf("Check(%s %s %s): got %v, expected allowed", test.op, test.path, test.name, err)
+			}
+			continue
+		}
+		e, ok := err.(*DeniedError)
+		if !ok || e.Rule != test.rule {
+			t.Errorf("Check(%s %s %s): got %v, expected denial by rule %d", test.op, test.path, test.name, err, test.rule)
+		}
+	}
+
+	allowAll := &Policy{Default: Allow}
+	if err := allowAll.Check(OpRemove, "/x", "trusted.y", 0); err != nil {
+		t.Errorf("Check(): got %v with default allow", err)
+	}
+
+	for _, bad := range []string{
+		`{"default": "maybe"}`,
+		`{"rules": [{"effect": "permit"}]}`,
+		`{"rules": [{"effect": "allow", "ops": ["get"]}]}`,
+		`{"rules": [{"effect": "allow", "names": ["["]}]}`,
+		`{"rules": [{"effect": "allow", "size": 1}]}`,
+	} {
+		if _, err := Load(strings.NewReader(bad)); err == nil {
+			t.Errorf("Load(%s): expected error", bad)
+		}
+	}
+}
+
+func TestSetRemove(t *testing.T) {
+	dir := xattrtest.TempDir(t)
+	file := filepath.Join(dir, "file")
+	ioutil.WriteFile(file, nil, 0644)
+
+	p := &Policy{Rules: []Rule{{Effect: Allow, Namespaces: []string{"user.app."}, Paths: []string{dir}}}}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Validate() failed: %v", err)
+	}
+
+	if err := p.Set(file, "user.app.state", []byte("ok")); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if err := p.Set(file, "user.other", []byte("no")); !IsDenied(err) {
+		t.Errorf("Set(): got %v, expected denial", err)
+	}
+	if _, err := xattr.Get(file, "user.other"); !xattr.IsNotExist(err) {
+		t.Errorf("Set(): denied attribute was written")
+	}
+	if err := p.Remove(file, "user.app.state"); err != nil {
+		t.Errorf("Remove() failed: %v", err)
+	}
+}
+
+func TestSymlink(t *testing.T) {
+	dir := xattrtest.TempDir(t)
+	allowed := filepath.Join(dir, "allowed")
+	outside := filepath.Join(dir, "outside")
+	os.Mkdir(allowed, 0755)
+	ioutil.WriteFile(outside, nil, 0644)
+	if err := os.Symlink(outside, filepath.Join(allowed, "link")); err != nil {
+		t.Fatalf("Symlink() failed: %v", err)
+	}
+	if err := os.Symlink(dir, filepath.Join(allowed, "up")); err != nil {
+		t.Fatalf("Symlink() failed: %v", err)
+	}
+
+	p := &Policy{Rules: []Rule{{Effect: Allow, Paths: []string{allowed}}}}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Validate() failed: %v", err)
+	}
+	for _, path := range []string{
+		filepath.Join(allowed, "link"),
+		filepath.Join(allowed, "up", "outside"),
+		filepath.Join(allowed, "up", "missing"),
+	} {
+		if err := p.Check(OpSet, path, "user.x", 1); !IsDenied(err) {
+			t.Errorf("Check(%s): got %v, expected denial", path, err)
+		}
+	}
+	if err := p.Check(OpSet, filepath.Join(allowed, "new"), "user.x", 1); err != nil {
+		t.Errorf("Check(): got %v for a new file below an allowed path", err)
+	}
+}
@@ -0,0 +1,356 @@
+// Package provenance attaches build provenance to artifacts as an in-toto
+// statement with a SLSA provenance predicate, stored in extended
+// attributes of the artifact itself.
+//
+// The statement is stored as gzip compressed JSON split into chunks of at
+// most ChunkSize bytes, user.provenance.0, user.provenance.1 and so on,
+// which keeps each value within the limits of filesystems like ext4 that
+// store attributes in a single block. user.provenance is written last and
+// holds "v1 <chunks> <sha256 of the compressed data>", so readers never see
+// a partially written statement as valid.
+package provenance
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ivaxer/go-xattr"
+)
+
+// Attr is the attribute holding the chunk index.
+const Attr = "user.provenance"
+
+// ChunkSize is the maximum length of a chunk value.
+const ChunkSize = 3072
+
+// MaxStatementSize is the maximum length of a statement as JSON. Read
+// stops decompressing there, so a small corrupt or hostile value can't
+// expand to exhaust memory.
+const MaxStatementSize = 1 << 20
+
+// Type URIs.
+const (
+	StatementType  = "https://in-toto.io/Statement/v1"
+	PredicateSLSA1 = "https://slsa.dev/provenance/v1"
+)
+
+// chunkSize and maxStatementSize are ChunkSize and MaxStatementSize,
+// lowered by tests.
+var (
+	chunkSize        = ChunkSize
+	maxStatementSize = MaxStatementSize
+)
+
+// maxChunks bounds the statements read back, to guard against corrupt
+// indexes.
+const maxChunks = 1024
+
+var (
+	// ErrCorrupt is returned when the stored statement can't be decoded.
+	ErrCorrupt = errors.New("provenance: corrupt statement")
+	// ErrSubjectMismatch is returned by Verify when the file content
+	// doesn't match any subject of the statement.
+	ErrSubjectMismatch = errors.New("provenance: subject digest mismatch")
+	// ErrTooLarge is returned for statements longer than
+	// MaxStatementSize.
+	ErrTooLarge = errors.New("provenance: statement too large")
+)
+
+// Statement is an in-toto statement with a SLSA provenance predicate.
+type Statement struct {
+	Type          string     `json:"_type"`
+	Subject       []Subject  `json:"subject"`
+	PredicateType string     `json:"predicateType"`
+	Predicate     Provenance `json:"predicate"`
+}
+
+// Subject is an artifact the statement is about.
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Provenance is a SLSA v1 provenance predicate.
+type Provenance struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+// BuildDefinition describes the inputs of a build.
+type BuildDefinition struct {
+	BuildType            string                 `json:"buildType"`
+	ExternalParameters   map[string]interface{} `json:"externalParameters"`
+	InternalParameters   map[string]interface{} `json:"internalParameters,omitempty"`
+	ResolvedDependencies []ResourceDescriptor   `json:"resolvedDependencies,omitempty"`
+}
+
+// ResourceDescriptor identifies a material of the build, such as the
+// source repository at a commit: {URI: "git+https://...", Digest:
+// {"gitCommit": "..."}}.
+type ResourceDescriptor struct {
+	URI    string            `json:"uri,omitempty"`
+	Name   string            `json:"name,omitempty"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// RunDetails describes the build run.
+type RunDetails struct {
+	Builder  Builder        `json:"builder"`
+	Metadata *BuildMetadata `json:"metadata,omitempty"`
+}
+
+// Builder identifies the build platform.
+type Builder struct {
+	ID string `json:"id"`
+}
+
+// BuildMetadata holds details of the build invocation.
+type BuildMetadata struct {
+	InvocationID string     `json:"invocationId,omitempty"`
+	StartedOn    *time.Time `json:"startedOn,omitempty"`
+	FinishedOn   *time.Time `json:"finishedOn,omitempty"`
+}
+
+// SourceCommit returns the commit of the first resolved dependency with a
+// gitCommit digest, or "".
+func (p *Provenance) SourceCommit() string {
+	for _, d := range p.BuildDefinition.ResolvedDependencies {
+		if c := d.Digest["gitCommit"]; c != "" {
+			return c
+		}
+	}
+	return ""
+}
+
+// FileDigest returns the hex encoded SHA-256 digest of the content of path.
+func FileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// NewStatement returns a statement with predicate p about the current
+// content of path.
+func NewStatement(path string, p Provenance) (*Statement, error) {
+	digest, err := FileDigest(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Statement{
+		Type:          StatementType,
+		Subject:       []Subject{{Name: filepath.Base(path), Digest: map[string]string{"sha256": digest}}},
+		PredicateType: PredicateSLSA1,
+		Predicate:     p,
+	}, nil
+}
+
+// Attach stores a statement with predicate p about the current content of
+// path in its attributes.
+func Attach(path string, p Provenance) error {
+	st, err := NewStatement(path, p)
+	if err != nil {
+		return err
+	}
+	return Write(path, st)
+}
+
+func chunkAttr(i int) string {
+	return Attr + "." + strconv.Itoa(i)
+}
+
+// Write stores st in the attributes of path, replacing any previous
+// statement.
+func Write(path string, st *Statement) error {
+	js, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	if len(js) > maxStatementSize {
+		return ErrTooLarge
+	}
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	zw.Write(js)
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+
+	old, _ := readIndex(path)
+
+	n := 0
+	for off := 0; off < len(data); off += chunkSize {
+		end := off + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		if err := xattr.Set(path, chunkAttr(n), data[off:end]); err != nil {
+			return err
+		}
+		n++
+	}
+	sum := sha256.Sum256(data)
+	if err := xattr.Set(path, Attr, []byte(fmt.Sprintf("v1 %d %x", n, sum))); err != nil {
+		return err
+	}
+
+	for i := n; i < old.chunks; i++ {
+		if err := xattr.Remove(path, chunkAttr(i)); err != nil && !xattr.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+type index struct {
+	chunks int
+	sum    []byte
+}
+
+func readIndex(path string) (index, error) {
+	b, err := xattr.Get(path, Attr)
+	if err != nil {
+		return index{}, err
+	}
+
+	var idx index
+	var sum string
+	if _, err := fmt.Sscanf(string(b), "v1 %d %s", &idx.chunks, &sum); err != nil {
+		return index{}, ErrCorrupt
+	}
+	if idx.sum, err = hex.DecodeString(sum); err != nil || len(idx.sum) != sha256.Size {
+		return index{}, ErrCorrupt
+	}
+	if idx.chunks < 1 || idx.chunks > maxChunks {
+		return index{}, ErrCorrupt
+	}
+	return idx, nil
+}
+
+// Read returns the statement stored on path. If there is none, the error
+// satisfies xattr.IsNotExist.
+func Read(path string) (*Statement, error) {
+	idx, err := readIndex(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	for i := 0; i < idx.chunks; i++ {
+		b, err := xattr.Get(path, chunkAttr(i))
+		if err != nil {
+			if xattr.IsNotExist(err) {
+				return nil, ErrCorrupt
+			}
+			return nil, err
+		}
+		data = append(data, b...)
+	}
+	if sum := sha256.Sum256(data); !bytes.Equal(sum[:], idx.sum) {
+		return nil, ErrCorrupt
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	js, err := ioutil.ReadAll(io.LimitReader(zr, int64(maxStatementSize)+1))
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	if len(js) > maxStatementSize {
+		return nil, ErrTooLarge
+	}
+
+	st := new(Statement)
+	if err := json.Unmarshal(js, st); err != nil {
+		return nil, fmt.Errorf("provenance: decoding statement: %v", err)
+	}
+	return st, nil
+}
+
+// Remove removes the statement stored on path. Chunks are found by name
+// rather than through the index, so none are left behind when the index
+// is corrupt or missing. If there is no statement, the error satisfies
+// xattr.IsNotExist.
+func Remove(path string) error {
+	names, err := xattr.List(path)
+	if err != nil {
+		return err
+	}
+	notExist := xattr.Remove(path, Attr)
+	if notExist != nil && !xattr.IsNotExist(notExist) {
+		return notExist
+	}
+	removed := notExist == nil
+	for _, name := range names {
+		if !isChunkAttr(name) {
+			continue
+		}
+		if err := xattr.Remove(path, name); err != nil {
+			if xattr.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		removed = true
+	}
+	if !removed {
+		return notExist
+	}
+	return nil
+}
+
+// isChunkAttr reports whether name is a chunk attribute, Attr followed by
+// a dot and a decimal index.
+func isChunkAttr(name string) bool {
+	s := strings.TrimPrefix(name, Attr+".")
+	if s == name || s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Verify reads the statement stored on path and checks that the current
+// content of path matches the sha256 digest of one of its subjects.
+func Verify(path string) (*Statement, error) {
+	st, err := Read(path)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := FileDigest(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range st.Subject {
+		if s.Digest["sha256"] == digest {
+			return st, nil
+		}
+	}
+	return st, ErrSubjectMismatch
+}
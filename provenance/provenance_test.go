@@ -0,0 +1,121 @@
+package provenance
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ivaxer/go-xattr"
+	"github.com/ivaxer/go-xattr/xattrtest"
+)
+
+func TestAttachVerify(t *testing.T) {
+	path := xattrtest.TempFile(t)
+	if err := ioutil.WriteFile(path, []byte("artifact"), 0644); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+
+	// Random parameters don't compress, so the statement spans chunks.
+	// Keep the total small enough for filesystems limiting attributes to
+	// one block per inode.
+	defer func(n int) { chunkSize = n }(chunkSize)
+	chunkSize = 256
+	noise := make([]byte, 400)
+	rand.Read(noise)
+
+	started := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	p := Provenance{
+		BuildDefinition: BuildDefinition{
+			BuildType:          "https://example.com/build/v1",
+			ExternalParameters: map[string]interface{}{"target": "//cmd:all", "noise": hex.EncodeToString(noise)},
+			ResolvedDependencies: []ResourceDescriptor{
+				{URI: "git+https://example.com/repo@refs/heads/main", Digest: map[string]string{"gitCommit": "7fd1a60b01f91b314f59955a4e4d4e80d8edf11d"}},
+				{URI: "pkg:golang/stdlib@1.21", Digest: map[string]string{"sha256": "00ff"}},
+			},
+		},
+		RunDetails: RunDetails{
+			Builder:  Builder{ID: "https://example.com/builders/ci"},
+			Metadata: &BuildMetadata{InvocationID: "build-42", StartedOn: &started},
+		},
+	}
+
+	if err := Attach(path, p); err != nil {
+		t.Fatalf("Attach() failed: %v", err)
+	}
+	idx, err := readIndex(path)
+	if err != nil || idx.chunks < 2 {
+		t.Errorf("readIndex(): got %+v, %v, expected several chunks", idx, err)
+	}
+
+	st, err := Verify(path)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if st.Type != StatementType || st.PredicateType != PredicateSLSA1 {
+		t.Errorf("Verify(): got types %q %q", st.Type, st.PredicateType)
+	}
+	if !reflect.DeepEqual(st.Predicate, p) {
+		t.Errorf("Verify(): got predicate %+v, expected %+v", st.Predicate, p)
+	}
+	if c := st.Predicate.SourceCommit(); c != "7fd1a60b01f91b314f59955a4e4d4e80d8edf11d" {
+		t.Errorf("SourceCommit(): got %q", c)
+	}
+
+	// A smaller statement removes the stale chunks.
+	p.BuildDefinition.ExternalParameters = nil
+	if err := Attach(path, p); err != nil {
+		t.Fatalf("Attach() failed: %v", err)
+	}
+	if _, err := xattr.Get(path, chunkAttr(idx.chunks-1)); !xattr.IsNotExist(err) {
+		t.Errorf("Attach(): stale chunk left behind: %v", err)
+	}
+
+	ioutil.WriteFile(path, []byte("artifact modified"), 0644)
+	if _, err := Verify(path); err != ErrSubjectMismatch {
+		t.Errorf("Verify(): got %v, expected ErrSubjectMismatch", err)
+	}
+
+	xattr.Set(path, chunkAttr(0), []byte("garbage"))
+	if _, err := Read(path); err != ErrCorrupt {
+		t.Errorf("Read(): got %v, expected ErrCorrupt", err)
+	}
+
+	// Chunks go even when the index can't be read.
+	xattr.Set(path, Attr, []byte("garbage"))
+	xattr.Set(path, Attr+".x", []byte("unrelated"))
+	if err := Remove(path); err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+	if _, err := Read(path); !xattr.IsNotExist(err) {
+		t.Errorf("Read(): got %v, expected not exist", err)
+	}
+	xattrtest.CheckList(t, xattrtest.OS, path, Attr+".x")
+	if err := Remove(path); !xattr.IsNotExist(err) {
+		t.Errorf("Remove(): got %v, expected not exist", err)
+	}
+}
+
+func TestTooLarge(t *testing.T) {
+	path := xattrtest.TempFile(t)
+	defer func(n int) { maxStatementSize = n }(maxStatementSize)
+
+	st, err := NewStatement(path, Provenance{})
+	if err != nil {
+		t.Fatalf("NewStatement() failed: %v", err)
+	}
+	if err := Write(path, st); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	// The statement was written under a higher limit.
+	maxStatementSize = 16
+	if _, err := Read(path); err != ErrTooLarge {
+		t.Errorf("Read(): got %v, expected ErrTooLarge", err)
+	}
+	if err := Write(path, st); err != ErrTooLarge {
+		t.Errorf("Write(): got %v, expected ErrTooLarge", err)
+	}
+}
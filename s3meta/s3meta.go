@@ -0,0 +1,242 @@
+// Package s3meta converts extended attributes to object store user
+// metadata headers and back.
+//
+// An attribute user.<name> becomes the header x-amz-meta-<name>. Header
+// names are case-insensitive and restricted to tokens, so bytes of <name>
+// other than lower case letters, digits, '-', '_' and '.' are written as
+// %XX. Values that aren't plain printable ASCII are written as RFC 2047
+// encoded words, "=?UTF-8?B?<base64>?=", which S3 and its clients already
+// use for non-ASCII metadata; binary values go through the same encoding.
+package s3meta
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"os"
+	"strings"
+
+	"github.com/ivaxer/go-xattr"
+)
+
+// Defaults of Options.
+const (
+	DefaultPrefix    = "x-amz-meta-"
+	DefaultNamespace = "user."
+	DefaultMaxSize   = 2048
+)
+
+// ErrTooLarge is returned when the encoded metadata exceeds the size
+// limit.
+var ErrTooLarge = errors.New("s3meta: metadata too large")
+
+// Options configure the conversion. Zero fields take the defaults.
+type Options struct {
+	// Prefix of header names.
+	Prefix string
+
+	// Namespace of the converted attributes, including the trailing dot.
+	// Attributes in other namespaces are ignored.
+	Namespace string
+
+	// MaxSize limits the sum of the lengths of the encoded names (without
+	// Prefix) and values, as S3 does. Negative means no limit.
+	MaxSize int
+
+	// Prune makes Import remove attributes of Namespace that are not in
+	// the metadata.
+	Prune bool
+}
+
+func (o *Options) withDefaults() Options {
+	var opts Options
+	if o != nil {
+		opts = *o
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = DefaultPrefix
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = DefaultNamespace
+	}
+	if opts.MaxSize == 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	return opts
+}
+
+// Export returns the attributes of path as metadata headers.
+func Export(path string, opts *Options) (map[string]string, error) {
+	o := opts.withDefaults()
+
+	names, err := xattr.List(path)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := make(map[string]string)
+	size := 0
+	for _, name := range names {
+		if !strings.HasPrefix(name, o.Namespace) {
+			continue
+		}
+		value, err := xattr.Get(path, name)
+		if err != nil {
+			if xattr.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		key := EncodeName(name[len(o.Namespace):])
+		v := EncodeValue(value)
+		size += len(key) + len(v)
+		if o.MaxSize > 0 && size > o.MaxSize {
+			return nil, &os.PathError{Op: "export", Path: path, Err: ErrTooLarge}
+		}
+		meta[o.Prefix+key] = v
+	}
+	return meta, nil
+}
+
+// Import sets the attributes described by the metadata headers on path.
+// Header names are matched case-insensitively; headers without Prefix are
+// ignored.
+func Import(path string, meta map[string]string, opts *Options) error {
+	o := opts.withDefaults()
+	prefix := strings.ToLower(o.Prefix)
+
+	attrs := make(map[string][]byte)
+	for key, v := range meta {
+		key = strings.ToLower(key)
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		name, err := DecodeName(key[len(prefix):])
+		if err != nil {
+			return err
+		}
+		value, err := DecodeValue(v)
+		if err != nil {
+			return err
+		}
+		attrs[o.Namespace+name] = value
+	}
+
+	for name, value := range attrs {
+		if err := xattr.Set(path, name, value); err != nil {
+			return err
+		}
+	}
+
+	if !o.Prune {
+		return nil
+	}
+	names, err := xattr.List(path)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, ok := attrs[name]; ok || !strings.HasPrefix(name, o.Namespace) {
+			continue
+		}
+		if err := xattr.Remove(path, name); err != nil && !xattr.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Escapes use lower case hex digits as header names may be lower-cased in
+// transit.
+const hexDigits = "0123456789abcdef"
+
+func isNameByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.'
+}
+
+// EncodeName encodes an attribute name, without namespace, for use in a
+// header name.
+func EncodeName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if isNameByte(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&15])
+		}
+	}
+	return b.String()
+}
+
+// DecodeName reverses EncodeName.
+func DecodeName(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", fmt.Errorf("s3meta: invalid escape in %q", s)
+		}
+		hi, lo := unhex(s[i+1]), unhex(s[i+2])
+		if hi < 0 || lo < 0 {
+			return "", fmt.Errorf("s3meta: invalid escape in %q", s)
+		}
+		b.WriteByte(byte(hi<<4 | lo))
+		i += 2
+	}
+	return b.String(), nil
+}
+
+func unhex(c byte) int {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c - '0')
+	case 'a' <= c && c <= 'f':
+		return int(c - 'a' + 10)
+	case 'A' <= c && c <= 'F':
+		return int(c - 'A' + 10)
+	}
+	return -1
+}
+
+// EncodeValue encodes an attribute value as a header value.
+func EncodeValue(value []byte) string {
+	if isPlain(value) {
+		return string(value)
+	}
+	return "=?UTF-8?B?" + base64.StdEncoding.EncodeToString(value) + "?="
+}
+
+// isPlain reports whether value can be used as a header value verbatim:
+// printable ASCII that survives whitespace trimming and doesn't look like
+// an encoded word.
+func isPlain(value []byte) bool {
+	n := len(value)
+	if n > 0 && (value[0] == ' ' || value[n-1] == ' ') {
+		return false
+	}
+	for _, c := range value {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return !strings.Contains(string(value), "=?")
+}
+
+// DecodeValue decodes a header value into an attribute value. Besides the
+// output of EncodeValue it accepts any RFC 2047 encoded words.
+func DecodeValue(s string) ([]byte, error) {
+	var dec mime.WordDecoder
+	v, err := dec.DecodeHeader(s)
+	if err != nil {
+		return nil, fmt.Errorf("s3meta: invalid value %q: %v", s, err)
+	}
+	return []byte(v), nil
+}
@@ -0,0 +1,115 @@
+package s3meta
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/ivaxer/go-xattr"
+	"github.com/ivaxer/go-xattr/xattrtest"
+)
+
+func TestEncoding(t *testing.T) {
+	names := []string{"mime_type", "Author Name", "100%", "ünïcode"}
+	for _, name := range names {
+		enc := EncodeName(name)
+		for i := 0; i < len(enc); i++ {
+			if !isNameByte(enc[i]) && enc[i] != '%' {
+				t.Errorf("EncodeName(%q) = %q: invalid header byte %q", name, enc, enc[i])
+			}
+		}
+		if got, err := DecodeName(enc); err != nil || got != name {
+			t.Errorf("DecodeName(%q): got %q, %v, expected %q", enc, got, err, name)
+		}
+	}
+
+	values := [][]byte{
+		[]byte("text/plain"),
+		[]byte(" padded "),
+		[]byte("=?UTF-8?B?lookalike?="),
+		[]byte("Grüße"),
+		{0, 1, 2, 0xff},
+		{},
+	}
+	for _, value := range values {
+		enc := EncodeValue(value)
+		if got, err := DecodeValue(enc); err != nil || !bytes.Equal(got, value) {
+			t.Errorf("DecodeValue(%q): got %q, %v, expected %q", enc, got, err, value)
+		}
+	}
+	if enc := EncodeValue([]byte("text/plain")); enc != "text/plain" {
+		t.Errorf("EncodeValue(): plain value encoded as %q", enc)
+	}
+
+	for _, s := range []string{"%", "%4", "%zz"} {
+		if _, err := DecodeName(s); err == nil {
+			t.Errorf("DecodeName(%q): expected error", s)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	src := xattrtest.TempFile(t)
+	dst := xattrtest.TempFile(t)
+
+	attrs := map[string][]byte{
+		"user.mime_type":   []byte("image/png"),
+		"user.Digest SHA1": {0xda, 0x39, 0xa3, 0xee},
+		"user.title":       []byte("Grüße"),
+	}
+	for name, value := range attrs {
+		if err := xattr.Set(src, name, value); err != nil {
+			t.Fatalf("Set(%q) failed: %v", name, err)
+		}
+	}
+	if err := xattr.Set(dst, "user.stale", []byte("x")); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+
+	meta, err := Export(src, nil)
+	if err != nil {
+		t.Fatalf("Export() failed: %v", err)
+	}
+	if meta["x-amz-meta-mime_type"] != "image/png" {
+		t.Errorf("Export(): unexpected metadata %v", meta)
+	}
+
+	// Servers may return header names in canonical case.
+	meta["X-Amz-Meta-Mime_type"] = meta["x-amz-meta-mime_type"]
+	delete(meta, "x-amz-meta-mime_type")
+	meta["Content-Type"] = "ignored"
+
+	if err := Import(dst, meta, &Options{Prune: true}); err != nil {
+		t.Fatalf("Import() failed: %v", err)
+	}
+
+	names, err := xattr.List(dst)
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(names) != len(attrs) {
+		t.Errorf("Import(): got attributes %v", names)
+	}
+	for name, value := range attrs {
+		got, err := xattr.Get(dst, name)
+		if err != nil || !bytes.Equal(got, value) {
+			t.Errorf("Get(%q): got %q, %v, expected %q", name, got, err, value)
+		}
+	}
+}
+
+func TestExportTooLarge(t *testing.T) {
+	f := xattrtest.TempFile(t)
+
+	if err := xattr.Set(f, "user.big", make([]byte, 100)); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+
+	_, err := Export(f, &Options{MaxSize: 64})
+	if e, ok := err.(*os.PathError); !ok || e.Err != ErrTooLarge {
+		t.Errorf("Export(): unexpected error value: %v", err)
+	}
+	if _, err := Export(f, &Options{MaxSize: -1}); err != nil {
+		t.Errorf("Export() without limit failed: %v", err)
+	}
+}
@@ -0,0 +1,268 @@
+// Package sign stores detached Ed25519 signatures over file content and
+// selected extended attributes, so files can be checked by anyone holding
+// the public key.
+//
+// The signed digest is SHA-256 over a canonical encoding of the content
+// digest and of each covered attribute, in name order, including whether
+// it is present; removing a covered attribute therefore invalidates the
+// signature as much as changing it. The signature is stored as JSON in
+// user.signature:
+//
+//	{"v":1,"key":"3f2a...","attrs":["user.mime_type"],"sig":"base64..."}
+//
+// The list of covered attributes is part of the digest.
+package sign
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ivaxer/go-xattr"
+)
+
+// DefaultAttr is the attribute holding the signature.
+const DefaultAttr = "user.signature"
+
+const (
+	version    = 1
+	domainText = "go-xattr signature v1\x00"
+)
+
+var (
+	// ErrUnsigned is returned by Verify for files without a signature.
+	ErrUnsigned = errors.New("sign: file is not signed")
+	// ErrUnknownKey is returned by Verify for signatures made with a key
+	// the verifier doesn't have.
+	ErrUnknownKey = errors.New("sign: unknown signing key")
+	// ErrTampered is returned by Verify when the signature doesn't match
+	// the file.
+	ErrTampered = errors.New("sign: signature mismatch")
+)
+
+// signature is the stored form of a signature.
+type signature struct {
+	Version int      `json:"v"`
+	KeyID   string   `json:"key"`
+	Attrs   []string `json:"attrs"`
+	Sig     []byte   `json:"sig"`
+}
+
+// KeyID returns the identifier of a public key: the hex encoded first 8
+// bytes of its SHA-256 digest.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Digest returns the digest of the content of path and of the attributes
+// in attrs, which is what gets signed.
+func Digest(path string, attrs []string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	content := sha256.New()
+	if _, err := io.Copy(content, f); err != nil {
+		return nil, err
+	}
+
+	names := append([]string(nil), attrs...)
+	sort.Strings(names)
+
+	h := sha256.New()
+	h.Write([]byte(domainText))
+	h.Write(content.Sum(nil))
+	writeUint(h, uint64(len(names)))
+	for _, name := range names {
+		writeBytes(h, []byte(name))
+		value, err := xattr.Get(path, name)
+		switch {
+		case err == nil:
+			h.Write([]byte{1})
+			writeBytes(h, value)
+		case xattr.IsNotExist(err):
+			h.Write([]byte{0})
+		default:
+			return nil, err
+		}
+	}
+	return h.Sum(nil), nil
+}
+
+func writeUint(h hash.Hash, n uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], n)
+	h.Write(b[:])
+}
+
+func writeBytes(h hash.Hash, b []byte) {
+	writeUint(h, uint64(len(b)))
+	h.Write(b)
+}
+
+// Signer signs files.
+type Signer struct {
+	Key ed25519.PrivateKey
+
+	// Attrs lists the attributes covered by signatures.
+	Attrs []string
+
+	// Attr is the attribute holding the signature. Empty means
+	// DefaultAttr.
+	Attr string
+}
+
+func attrName(attr string) string {
+	if attr == "" {
+		return DefaultAttr
+	}
+	return attr
+}
+
+// Sign signs path and stores the signature in its signature attribute.
+func (s *Signer) Sign(path string) error {
+	attr := attrName(s.Attr)
+	for _, name := range s.Attrs {
+		if name == attr {
+			return fmt.Errorf("sign: signature attribute %s can't be covered", attr)
+		}
+	}
+
+	digest, err := Digest(path, s.Attrs)
+	if err != nil {
+		return err
+	}
+	sig := signature{
+		Version: version,
+		KeyID:   KeyID(s.Key.Public().(ed25519.PublicKey)),
+		Attrs:   append([]string{}, s.Attrs...),
+		Sig:     ed25519.Sign(s.Key, digest),
+	}
+	sort.Strings(sig.Attrs)
+
+	b, err := json.Marshal(sig)
+	if err != nil {
+		return err
+	}
+	return xattr.Set(path, attr, b)
+}
+
+// Verifier checks signatures.
+type Verifier struct {
+	// Keys holds the trusted public keys.
+	Keys []ed25519.PublicKey
+
+	// Attr is the attribute holding the signature. Empty means
+	// DefaultAttr.
+	Attr string
+}
+
+// Verify checks the signature of path. It returns the ID of the signing
+// key on success, and ErrUnsigned, ErrUnknownKey or ErrTampered if the
+// file doesn't carry a valid signature. A signature that can't be parsed
+// counts as tampered.
+func (v *Verifier) Verify(path string) (string, error) {
+	b, err := xattr.Get(path, attrName(v.Attr))
+	if err != nil {
+		if xattr.IsNotExist(err) {
+			return "", ErrUnsigned
+		}
+		return "", err
+	}
+
+	var sig signature
+	if err := json.Unmarshal(b, &sig); err != nil || sig.Version != version {
+		return "", ErrTampered
+	}
+
+	var key ed25519.PublicKey
+	for _, k := range v.Keys {
+		if KeyID(k) == sig.KeyID {
+			key = k
+			break
+		}
+	}
+	if key == nil {
+		return sig.KeyID, ErrUnknownKey
+	}
+
+	digest, err := Digest(path, sig.Attrs)
+	if err != nil {
+		return sig.KeyID, err
+	}
+	if !ed25519.Verify(key, digest, sig.Sig) {
+		return sig.KeyID, ErrTampered
+	}
+	return sig.KeyID, nil
+}
+
+// Status is the outcome of verifying a file.
+type Status int
+
+// Statuses.
+const (
+	Unsigned Status = iota
+	Valid
+	Tampered
+	UnknownKey
+	Failed // the file couldn't be read
+)
+
+var statusNames = []string{"unsigned", "valid", "tampered", "unknown key", "failed"}
+
+func (s Status) String() string {
+	if int(s) < len(statusNames) {
+		return statusNames[s]
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+// Result is the outcome of verifying one file of a tree.
+type Result struct {
+	Path   string
+	Status Status
+	KeyID  string
+	Err    error // for Failed
+}
+
+// VerifyTree verifies every regular file below root, in lexical order,
+// and calls fn with the result. Walking stops at the first error returned
+// by fn.
+func (v *Verifier) VerifyTree(root string, fn func(Result) error) error {
+	return filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return fn(Result{Path: path, Status: Failed, Err: err})
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		r := Result{Path: path}
+		r.KeyID, err = v.Verify(path)
+		switch err {
+		case nil:
+			r.Status = Valid
+		case ErrUnsigned:
+			r.Status = Unsigned
+		case ErrUnknownKey:
+			r.Status = UnknownKey
+		case ErrTampered:
+			r.Status = Tampered
+		default:
+			r.Status, r.Err = Failed, err
+		}
+		return fn(r)
+	})
+}
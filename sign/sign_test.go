@@ -0,0 +1,87 @@
+package sign
+
+import (
+	"crypto/ed25519"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/ivaxer/go-xattr"
+	"github.com/ivaxer/go-xattr/xattrtest"
+)
+
+func newKey(t *testing.T) ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey() failed: %v", err)
+	}
+	return key
+}
+
+func TestSignVerify(t *testing.T) {
+	dir := xattrtest.TempDir(t)
+
+	key, other := newKey(t), newKey(t)
+	s := &Signer{Key: key, Attrs: []string{"user.mime_type", "user.origin"}}
+	v := &Verifier{Keys: []ed25519.PublicKey{key.Public().(ed25519.PublicKey)}}
+
+	files := map[string]Status{
+		"valid":      Valid,
+		"content":    Tampered,
+		"attr":       Tampered,
+		"removed":    Tampered,
+		"unsigned":   Unsigned,
+		"unknownkey": UnknownKey,
+		"uncovered":  Valid,
+	}
+	for name := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte("payload"), 0644); err != nil {
+			t.Fatalf("WriteFile() failed: %v", err)
+		}
+		if err := xattr.Set(path, "user.mime_type", []byte("text/plain")); err != nil {
+			t.Fatalf("Set() failed: %v", err)
+		}
+		switch name {
+		case "unsigned":
+		case "unknownkey":
+			if err := (&Signer{Key: other}).Sign(path); err != nil {
+				t.Fatalf("Sign() failed: %v", err)
+			}
+		default:
+			if err := s.Sign(path); err != nil {
+				t.Fatalf("Sign() failed: %v", err)
+			}
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "content"), []byte("Payload"), 0644); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+	xattr.Set(filepath.Join(dir, "attr"), "user.mime_type", []byte("text/html"))
+	xattr.Remove(filepath.Join(dir, "removed"), "user.mime_type")
+	xattr.Set(filepath.Join(dir, "uncovered"), "user.comment", []byte("not signed"))
+
+	if id, err := v.Verify(filepath.Join(dir, "valid")); err != nil || id != KeyID(key.Public().(ed25519.PublicKey)) {
+		t.Errorf("Verify(): got %q, %v", id, err)
+	}
+
+	seen := 0
+	err := v.VerifyTree(dir, func(r Result) error {
+		seen++
+		if expected := files[filepath.Base(r.Path)]; r.Status != expected {
+			t.Errorf("VerifyTree(): %s: got %v (%v), expected %v", r.Path, r.Status, r.Err, expected)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("VerifyTree() failed: %v", err)
+	}
+	if seen != len(files) {
+		t.Errorf("VerifyTree(): got %d results, expected %d", seen, len(files))
+	}
+
+	if err := (&Signer{Key: key, Attrs: []string{DefaultAttr}}).Sign(filepath.Join(dir, "valid")); err == nil {
+		t.Error("Sign(): expected error when covering the signature attribute")
+	}
+}
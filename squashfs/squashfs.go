@@ -0,0 +1,387 @@
+// Package squashfs reads extended attributes from SquashFS images without
+// mounting them.
+//
+// Image has the List and Get methods of the xattr package, taking paths
+// inside the image. Only metadata is read: the inode and directory tables
+// to resolve paths, and the xattr id table and key/value blocks. Metadata
+// compressed with gzip, the mksquashfs default, is supported with the
+// standard library; images using other compressors can only be read if
+// their metadata is stored uncompressed (mksquashfs -noI -noX).
+package squashfs
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+)
+
+const (
+	magic          = 0x73717368
+	superblockSize = 96
+
+	compressorGzip = 1
+
+	metadataSize       = 8192
+	metadataUncompress = 0x8000
+
+	noTable = 0xffffffffffffffff
+	noXattr = 0xffffffff
+)
+
+// Inode types.
+const (
+	typeDir = 1 + iota
+	typeFile
+	typeSymlink
+	typeBlockDev
+	typeCharDev
+	typeFifo
+	typeSocket
+	typeExtDir
+	typeExtFile
+	typeExtSymlink
+	typeExtBlockDev
+	typeExtCharDev
+	typeExtFifo
+	typeExtSocket
+)
+
+// ErrCorrupt is returned when the image contains inconsistent metadata.
+var ErrCorrupt = errors.New("squashfs: corrupt image")
+
+// Image is a SquashFS 4.0 image.
+type Image struct {
+	r io.ReaderAt
+	c io.Closer
+
+	compressor  uint16
+	rootInode   uint64
+	inodeTable  int64
+	dirTable    int64
+	xattrTable  uint64
+	kvStart     int64
+	xattrIDs    uint32
+	xattrBlocks []int64
+}
+
+// Open opens the image in the named file.
+func Open(name string) (*Image, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	img, err := New(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	img.c = f
+	return img, nil
+}
+
+// New reads the image from r.
+func New(r io.ReaderAt) (*Image, error) {
+	sb := make([]byte, superblockSize)
+	if _, err := r.ReadAt(sb, 0); err != nil {
+		return nil, fmt.Errorf("squashfs: reading superblock: %v", err)
+	}
+	if binary.LittleEndian.Uint32(sb) != magic {
+		return nil, errors.New("squashfs: not a squashfs image")
+	}
+	if major := binary.LittleEndian.Uint16(sb[28:]); major != 4 {
+		return nil, fmt.Errorf("squashfs: unsupported version %d", major)
+	}
+
+	img := &Image{
+		r:          r,
+		compressor: binary.LittleEndian.Uint16(sb[20:]),
+		rootInode:  binary.LittleEndian.Uint64(sb[32:]),
+		xattrTable: binary.LittleEndian.Uint64(sb[56:]),
+		inodeTable: int64(binary.LittleEndian.Uint64(sb[64:])),
+		dirTable:   int64(binary.LittleEndian.Uint64(sb[72:])),
+	}
+
+	if img.xattrTable != noTable {
+		hdr := make([]byte, 16)
+		if err := img.readAt(hdr, int64(img.xattrTable)); err != nil {
+			return nil, err
+		}
+		img.kvStart = int64(binary.LittleEndian.Uint64(hdr))
+		img.xattrIDs = binary.LittleEndian.Uint32(hdr[8:])
+
+		n := (int(img.xattrIDs)*16 + metadataSize - 1) / metadataSize
+		locs := make([]byte, 8*n)
+		if err := img.readAt(locs, int64(img.xattrTable)+16); err != nil {
+			return nil, err
+		}
+		for i := 0; i < n; i++ {
+			img.xattrBlocks = append(img.xattrBlocks, int64(binary.LittleEndian.Uint64(locs[8*i:])))
+		}
+	}
+	return img, nil
+}
+
+// Close closes the file opened by Open.
+func (img *Image) Close() error {
+	if img.c == nil {
+		return nil
+	}
+	return img.c.Close()
+}
+
+func (img *Image) readAt(b []byte, off int64) error {
+	if _, err := img.r.ReadAt(b, off); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrCorrupt
+		}
+		return err
+	}
+	return nil
+}
+
+// metaReader reads a stream of metadata blocks.
+type metaReader struct {
+	img  *Image
+	next int64
+	buf  []byte
+}
+
+// metadata returns a reader positioned at ref, which holds the offset of
+// a metadata block from table in its upper bits and the offset into the
+// uncompressed block in the lower 16 bits.
+func (img *Image) metadata(table int64, ref uint64) (*metaReader, error) {
+	m := &metaReader{img: img, next: table + int64(ref>>16)}
+	return m, m.skip(int(ref & 0xffff))
+}
+
+func (m *metaReader) fill() error {
+	var hdr [2]byte
+	if err := m.img.readAt(hdr[:], m.next); err != nil {
+		return err
+	}
+	h := binary.LittleEndian.Uint16(hdr[:])
+	size := int(h &^ metadataUncompress)
+	if size == 0 || size > metadataSize {
+		return ErrCorrupt
+	}
+
+	b := make([]byte, size)
+	if err := m.img.readAt(b, m.next+2); err != nil {
+		return err
+	}
+	m.next += 2 + int64(size)
+
+	if h&metadataUncompress != 0 {
+		m.buf = b
+		return nil
+	}
+	if m.img.compressor != compressorGzip {
+		return fmt.Errorf("squashfs: unsupported compressor %d", m.img.compressor)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return ErrCorrupt
+	}
+	if m.buf, err = ioutil.ReadAll(io.LimitReader(zr, metadataSize)); err != nil {
+		return ErrCorrupt
+	}
+	return nil
+}
+
+func (m *metaReader) Read(p []byte) (int, error) {
+	if len(m.buf) == 0 {
+		if err := m.fill(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, m.buf)
+	m.buf = m.buf[n:]
+	return n, nil
+}
+
+func (m *metaReader) read(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := io.ReadFull(m, b)
+	return b, err
+}
+
+func (m *metaReader) skip(n int) error {
+	_, err := m.read(n)
+	return err
+}
+
+func (m *metaReader) uint16() (uint16, error) {
+	b, err := m.read(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(b), nil
+}
+
+func (m *metaReader) uint32() (uint32, error) {
+	b, err := m.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+func (m *metaReader) uint64() (uint64, error) {
+	b, err := m.read(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+// inode holds the parts of an inode the reader needs.
+type inode struct {
+	typ   uint16
+	xattr uint32
+
+	// Directories only.
+	dirBlock  uint32
+	dirOffset uint16
+	dirSize   uint32
+}
+
+func (img *Image) readInode(ref uint64) (*inode, error) {
+	m, err := img.metadata(img.inodeTable, ref)
+	if err != nil {
+		return nil, err
+	}
+	hdr, err := m.read(16)
+	if err != nil {
+		return nil, err
+	}
+	ino := &inode{typ: binary.LittleEndian.Uint16(hdr), xattr: noXattr}
+
+	switch ino.typ {
+	case typeDir:
+		b, err := m.read(16)
+		if err != nil {
+			return nil, err
+		}
+		ino.dirBlock = binary.LittleEndian.Uint32(b[0:])
+		ino.dirSize = uint32(binary.LittleEndian.Uint16(b[8:]))
+		ino.dirOffset = binary.LittleEndian.Uint16(b[10:])
+	case typeExtDir:
+		b, err := m.read(24)
+		if err != nil {
+			return nil, err
+		}
+		ino.dirSize = binary.LittleEndian.Uint32(b[4:])
+		ino.dirBlock = binary.LittleEndian.Uint32(b[8:])
+		ino.dirOffset = binary.LittleEndian.Uint16(b[18:])
+		ino.xattr = binary.LittleEndian.Uint32(b[20:])
+	case typeExtFile:
+		b, err := m.read(40)
+		if err != nil {
+			return nil, err
+		}
+		ino.xattr = binary.LittleEndian.Uint32(b[36:])
+	case typeExtSymlink:
+		b, err := m.read(8)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.skip(int(binary.LittleEndian.Uint32(b[4:]))); err != nil {
+			return nil, err
+		}
+		if ino.xattr, err = m.uint32(); err != nil {
+			return nil, err
+		}
+	case typeExtBlockDev, typeExtCharDev:
+		b, err := m.read(12)
+		if err != nil {
+			return nil, err
+		}
+		ino.xattr = binary.LittleEndian.Uint32(b[8:])
+	case typeExtFifo, typeExtSocket:
+		b, err := m.read(8)
+		if err != nil {
+			return nil, err
+		}
+		ino.xattr = binary.LittleEndian.Uint32(b[4:])
+	case typeFile, typeSymlink, typeBlockDev, typeCharDev, typeFifo, typeSocket:
+		// Basic inodes have no attributes.
+	default:
+		return nil, ErrCorrupt
+	}
+	return ino, nil
+}
+
+// lookup returns the inode of the file at name. Symbolic links are not
+// followed.
+func (img *Image) lookup(name string) (*inode, error) {
+	ino, err := img.readInode(img.rootInode)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, elem := range strings.Split(path.Clean("/"+name), "/") {
+		if elem == "" {
+			continue
+		}
+		if ino.typ != typeDir && ino.typ != typeExtDir {
+			return nil, syscall.ENOTDIR
+		}
+		ref, err := img.findEntry(ino, elem)
+		if err != nil {
+			return nil, err
+		}
+		if ino, err = img.readInode(ref); err != nil {
+			return nil, err
+		}
+	}
+	return ino, nil
+}
+
+// findEntry looks name up in the directory dir and returns the inode
+// reference of the entry.
+func (img *Image) findEntry(dir *inode, name string) (uint64, error) {
+	// The size of a directory includes 3 bytes for "." and "..", which
+	// aren't stored.
+	if dir.dirSize <= 3 {
+		return 0, syscall.ENOENT
+	}
+	m, err := img.metadata(img.dirTable, uint64(dir.dirBlock)<<16|uint64(dir.dirOffset))
+	if err != nil {
+		return 0, err
+	}
+
+	for left := int(dir.dirSize) - 3; left > 0; {
+		hdr, err := m.read(12)
+		if err != nil {
+			return 0, err
+		}
+		count := int(binary.LittleEndian.Uint32(hdr)) + 1
+		start := uint64(binary.LittleEndian.Uint32(hdr[4:]))
+		left -= 12
+
+		for i := 0; i < count; i++ {
+			e, err := m.read(8)
+			if err != nil {
+				return 0, err
+			}
+			n := int(binary.LittleEndian.Uint16(e[6:])) + 1
+			entryName, err := m.read(n)
+			if err != nil {
+				return 0, err
+			}
+			left -= 8 + n
+			if string(entryName) == name {
+				return start<<16 | uint64(binary.LittleEndian.Uint16(e)), nil
+			}
+		}
+	}
+	return 0, syscall.ENOENT
+}
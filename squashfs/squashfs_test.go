@@ -0,0 +1,195 @@
+package squashfs
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ivaxer/go-xattr"
+)
+
+// builder assembles a SquashFS image in memory. The tests build images
+// by hand since mksquashfs is rarely installed.
+type builder struct {
+	bytes.Buffer
+}
+
+func (b *builder) le(v ...interface{}) {
+	for _, x := range v {
+		binary.Write(b, binary.LittleEndian, x)
+	}
+}
+
+// metablock appends data as one metadata block and returns its position.
+func (b *builder) metablock(data []byte, compress bool) int64 {
+	pos := int64(b.Len())
+	if !compress {
+		b.le(uint16(len(data)) | metadataUncompress)
+		b.Write(data)
+		return pos
+	}
+	var z bytes.Buffer
+	w := zlib.NewWriter(&z)
+	w.Write(data)
+	w.Close()
+	b.le(uint16(z.Len()))
+	b.Write(z.Bytes())
+	return pos
+}
+
+func le(v ...interface{}) []byte {
+	var b builder
+	b.le(v...)
+	return b.Bytes()
+}
+
+// mkimage builds an image with a root directory holding the file "file"
+// and the directory "dir", which in turn holds "plain" without attributes.
+func mkimage(compress bool) []byte {
+	// Inodes, all in one block. References are block<<16 | offset.
+	var inodes builder
+	dirInode := func(number uint32, listing uint16, size uint32, xattr uint32) uint64 {
+		ref := uint64(inodes.Len())
+		inodes.le(uint16(typeExtDir), uint16(0755), uint16(0), uint16(0), uint32(0), number)
+		inodes.le(uint32(2), size, uint32(0), uint32(1), uint16(0), listing, xattr)
+		return ref
+	}
+	fileInode := func(number uint32, xattr uint32) uint64 {
+		ref := uint64(inodes.Len())
+		if xattr == noXattr {
+			inodes.le(uint16(typeFile), uint16(0644), uint16(0), uint16(0), uint32(0), number)
+			inodes.le(uint32(0), uint32(noXattr), uint32(0), uint32(0))
+			return ref
+		}
+		inodes.le(uint16(typeExtFile), uint16(0644), uint16(0), uint16(0), uint32(0), number)
+		inodes.le(uint64(0), uint64(0), uint64(0), uint32(1), uint32(noXattr), uint32(0), xattr)
+		return ref
+	}
+
+	// Directory listings, all in one block.
+	var dirs builder
+	listing := func(entries map[string]uint64) (uint16, uint32) {
+		offset := uint16(dirs.Len())
+		names := make([]string, 0, len(entries))
+		for name := range entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		dirs.le(uint32(len(names)-1), uint32(0), uint32(1))
+		for _, name := range names {
+			dirs.le(uint16(entries[name]), int16(0), uint16(typeFile), uint16(len(name)-1))
+			dirs.WriteString(name)
+		}
+		return offset, uint32(dirs.Len()) - uint32(offset) + 3
+	}
+
+	plain := fileInode(4, noXattr)
+	sub, subSize := listing(map[string]uint64{"plain": plain})
+	dir := dirInode(3, sub, subSize, noXattr)
+	file := fileInode(2, 0)
+	top, topSize := listing(map[string]uint64{"file": file, "dir": dir})
+	root := dirInode(1, top, topSize, 1)
+
+	// Key/value pairs. The root's trusted.ool value refers to the value
+	// of the file's user.color.
+	var kv builder
+	kv.le(uint16(0), uint16(5))
+	kv.WriteString("color")
+	colorValue := uint64(kv.Len())
+	kv.le(uint32(3))
+	kv.WriteString("red")
+	kv.le(uint16(2), uint16(7))
+	kv.WriteString("selinux")
+	kv.le(uint32(6))
+	kv.WriteString("label\x00")
+	rootKV := uint64(kv.Len())
+	kv.le(uint16(1|xattrValueOOL), uint16(3))
+	kv.WriteString("ool")
+	kv.le(uint32(8), colorValue)
+
+	ids := le(uint64(0), uint32(2), uint32(0), rootKV, uint32(1), uint32(0))
+
+	var img builder
+	img.Write(make([]byte, superblockSize))
+	inodeTable := img.metablock(inodes.Bytes(), compress)
+	dirTable := img.metablock(dirs.Bytes(), compress)
+	kvStart := img.metablock(kv.Bytes(), compress)
+	idBlock := img.metablock(ids, compress)
+	xattrTable := int64(img.Len())
+	img.le(uint64(kvStart), uint32(2), uint32(0), uint64(idBlock))
+
+	sb := le(uint32(magic), uint32(4), uint32(0), uint32(131072), uint32(0),
+		uint16(compressorGzip), uint16(17), uint16(0), uint16(1), uint16(4), uint16(0),
+		root, uint64(img.Len()), uint64(noTable), uint64(xattrTable),
+		uint64(inodeTable), uint64(dirTable), uint64(noTable), uint64(noTable))
+	b := img.Bytes()
+	copy(b, sb)
+	return b
+}
+
+func TestImage(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		img, err := New(bytes.NewReader(mkimage(compress)))
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+
+		names, err := img.List("/file")
+		if err != nil {
+			t.Fatalf("List() failed: %v", err)
+		}
+		if got := strings.Join(names, ","); got != "user.color,security.selinux" {
+			t.Errorf("List(file): got %v", names)
+		}
+
+		tests := []struct {
+			path, attr, expected string
+		}{
+			{"file", "user.color", "red"},
+			{"/file", "security.selinux", "label\x00"},
+			{"/", "trusted.ool", "red"},
+		}
+		for _, test := range tests {
+			got, err := img.Get(test.path, test.attr)
+			if err != nil {
+				t.Errorf("Get(%q, %q) failed: %v", test.path, test.attr, err)
+			} else if string(got) != test.expected {
+				t.Errorf("Get(%q, %q): got %q, expected %q", test.path, test.attr, got, test.expected)
+			}
+		}
+
+		if names, err := img.List("dir/plain"); err != nil || len(names) != 0 {
+			t.Errorf("List(dir/plain): got %v, %v", names, err)
+		}
+		if _, err := img.Get("file", "user.missing"); !xattr.IsNotExist(err) {
+			t.Errorf("Get(): unexpected error value: %v", err)
+		}
+		if _, err := img.List("dir/missing"); !os.IsNotExist(err) {
+			t.Errorf("List(): unexpected error value: %v", err)
+		}
+		if _, err := img.List("file/below"); err == nil {
+			t.Error("List(): expected error on path below a file")
+		}
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	b := mkimage(true)
+	if _, err := New(bytes.NewReader(b[:50])); err == nil {
+		t.Error("New(): expected error on truncated superblock")
+	}
+
+	for _, n := range []int{120, 200} {
+		img, err := New(bytes.NewReader(b[:n]))
+		if err == nil {
+			_, err = img.List("file")
+		}
+		if err == nil {
+			t.Errorf("List(): expected error on image truncated to %d bytes", n)
+		}
+	}
+}
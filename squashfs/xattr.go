@@ -0,0 +1,153 @@
+package squashfs
+
+import (
+	"encoding/binary"
+	"os"
+
+	"github.com/ivaxer/go-xattr/internal/errno"
+)
+
+const (
+	xattrIDSize     = 16
+	xattrTypeMask   = 0xff
+	xattrValueOOL   = 0x100
+	maxXattrPerFile = 1 << 16
+)
+
+var prefixes = map[uint16]string{
+	0: "user.",
+	1: "trusted.",
+	2: "security.",
+}
+
+// List retrieves the names of the extended attributes of the file at path
+// in the image. Symbolic links are not followed. If there is an error, it
+// will be of type *os.PathError.
+func (img *Image) List(path string) ([]string, error) {
+	names := []string{}
+	err := img.walk(path, func(name string, m *metaReader, ool bool) (bool, error) {
+		names = append(names, name)
+		return false, skipValue(m)
+	})
+	if err != nil {
+		return nil, &os.PathError{Op: "listxattr", Path: path, Err: err}
+	}
+	return names, nil
+}
+
+// Get retrieves the value of the extended attribute attr of the file at
+// path in the image. If there is an error, it will be of type
+// *os.PathError.
+func (img *Image) Get(path, attr string) ([]byte, error) {
+	var value []byte
+	found := false
+	err := img.walk(path, func(name string, m *metaReader, ool bool) (bool, error) {
+		if name != attr {
+			return false, skipValue(m)
+		}
+		v, err := img.readValue(m, ool)
+		value, found = v, true
+		return true, err
+	})
+	if err == nil && !found {
+		err = errno.NoAttr
+	}
+	if err != nil {
+		return nil, &os.PathError{Op: "getxattr", Path: path, Err: err}
+	}
+	return value, nil
+}
+
+// walk calls f for each attribute of the file at path with the reader
+// positioned at the value, until f returns true.
+func (img *Image) walk(path string, f func(name string, m *metaReader, ool bool) (bool, error)) error {
+	ino, err := img.lookup(path)
+	if err != nil {
+		return err
+	}
+	if ino.xattr == noXattr {
+		return nil
+	}
+	if ino.xattr >= img.xattrIDs {
+		return ErrCorrupt
+	}
+
+	pos := int(ino.xattr) * xattrIDSize
+	m, err := img.metadata(img.xattrBlocks[pos/metadataSize], uint64(pos%metadataSize))
+	if err != nil {
+		return err
+	}
+	id, err := m.read(xattrIDSize)
+	if err != nil {
+		return err
+	}
+	ref := binary.LittleEndian.Uint64(id)
+	count := binary.LittleEndian.Uint32(id[8:])
+	if count > maxXattrPerFile {
+		return ErrCorrupt
+	}
+
+	if m, err = img.metadata(img.kvStart, ref); err != nil {
+		return err
+	}
+	for i := uint32(0); i < count; i++ {
+		typ, err := m.uint16()
+		if err != nil {
+			return err
+		}
+		size, err := m.uint16()
+		if err != nil {
+			return err
+		}
+		suffix, err := m.read(int(size))
+		if err != nil {
+			return err
+		}
+		prefix, ok := prefixes[typ&xattrTypeMask]
+		if !ok {
+			return ErrCorrupt
+		}
+
+		done, err := f(prefix+string(suffix), m, typ&xattrValueOOL != 0)
+		if err != nil || done {
+			return err
+		}
+	}
+	return nil
+}
+
+func skipValue(m *metaReader) error {
+	size, err := m.uint32()
+	if err != nil {
+		return err
+	}
+	return m.skip(int(size))
+}
+
+// readValue reads a value at m. Out of line values are stored as a
+// reference to the value in the key/value area.
+func (img *Image) readValue(m *metaReader, ool bool) ([]byte, error) {
+	size, err := m.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if ool {
+		if size != 8 {
+			return nil, ErrCorrupt
+		}
+		ref, err := m.uint64()
+		if err != nil {
+			return nil, err
+		}
+		if m, err = img.metadata(img.kvStart, ref); err != nil {
+			return nil, err
+		}
+		if size, err = m.uint32(); err != nil {
+			return nil, err
+		}
+	}
+	if size > 1<<16 {
+		return nil, ErrCorrupt
+	}
+	return m.read(int(size))
+}
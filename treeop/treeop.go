@@ -0,0 +1,284 @@
+// Package treeop throttles and checkpoints long running operations over
+// directory trees, such as copies, restores and migrations of attributes,
+// so they can run on production disks and resume after a crash.
+//
+// Trees are processed in walk order: depth first, parents before their
+// entries, entries in lexical order of their names, which is the order of
+// filepath.Walk. A Checkpoint records the last completed path in that
+// order; on restart every path up to it is skipped. The checkpoint is
+// saved at most once per SaveInterval, so up to that much work may be
+// redone after a crash and operations must be idempotent.
+package treeop
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limiter limits the rate of operations and of bytes processed. It spaces
+// requests evenly without bursts. A nil *Limiter doesn't limit.
+type Limiter struct {
+	opsRate   float64
+	bytesRate float64
+
+	mu        sync.Mutex
+	nextOp    time.Time
+	nextBytes time.Time
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// NewLimiter returns a limiter allowing ops operations and bytes bytes per
+// second. Zero means no limit.
+func NewLimiter(ops, bytes float64) *Limiter {
+	return &Limiter{opsRate: ops, bytesRate: bytes, now: time.Now, sleep: time.Sleep}
+}
+
+// Wait blocks until one operation processing n bytes may proceed. The
+// bytes are charged to the operations that follow, so a large file delays
+// the next operation rather than itself.
+func (l *Limiter) Wait(n int64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	now := l.now()
+	var d time.Duration
+	if l.opsRate > 0 {
+		d = reserve(&l.nextOp, now, time.Duration(float64(time.Second)/l.opsRate))
+	}
+	if l.bytesRate > 0 {
+		if bd := reserve(&l.nextBytes, now, time.Duration(float64(n)*float64(time.Second)/l.bytesRate)); bd > d {
+			d = bd
+		}
+	}
+	l.mu.Unlock()
+
+	if d > 0 {
+		l.sleep(d)
+	}
+}
+
+// reserve books cost after *next, or now if that is later, and returns how
+// long to wait for the booking to start.
+func reserve(next *time.Time, now time.Time, cost time.Duration) time.Duration {
+	start := *next
+	if start.Before(now) {
+		start = now
+	}
+	*next = start.Add(cost)
+	return start.Sub(now)
+}
+
+// SaveInterval is the minimum time between checkpoint saves.
+var SaveInterval = time.Second
+
+// ErrJobMismatch is returned by OpenCheckpoint when the checkpoint file
+// belongs to another job.
+var ErrJobMismatch = errors.New("treeop: checkpoint belongs to another job")
+
+type state struct {
+	Job  string `json:"job"`
+	Last string `json:"last"`
+}
+
+// Checkpoint records the progress of a job in a file. Paths are relative
+// to the root of the tree, with '/' separators.
+type Checkpoint struct {
+	name  string
+	state state
+	saved time.Time
+	dirty bool
+}
+
+// OpenCheckpoint opens the checkpoint file name for job, an identifier
+// of the operation such as its arguments, creating it on first save. A
+// file left by another job yields ErrJobMismatch.
+func OpenCheckpoint(name, job string) (*Checkpoint, error) {
+	c := &Checkpoint{name: name, state: state{Job: job}}
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+
+	var st state
+	if err := json.Unmarshal(b, &st); err != nil {
+		return nil, fmt.Errorf("treeop: invalid checkpoint %s: %v", name, err)
+	}
+	if st.Job != job {
+		return nil, ErrJobMismatch
+	}
+	c.state = st
+	return c, nil
+}
+
+// Last returns the last completed path, or "" if there is none.
+func (c *Checkpoint) Last() string {
+	return c.state.Last
+}
+
+// Completed reports whether rel was processed before the checkpoint was
+// taken, that is whether it comes before the last completed path in walk
+// order or is that path.
+func (c *Checkpoint) Completed(rel string) bool {
+	if c == nil || c.state.Last == "" {
+		return false
+	}
+	return compare(rel, c.state.Last) <= 0
+}
+
+// Within reports whether the last completed path is the directory rel or
+// below it. Such a directory is completed but may still have entries to
+// process.
+func (c *Checkpoint) Within(rel string) bool {
+	if c == nil || c.state.Last == "" {
+		return false
+	}
+	return rel == c.state.Last || isAncestor(rel, c.state.Last)
+}
+
+// Done records rel as completed, saving the checkpoint if SaveInterval has
+// passed since the last save.
+func (c *Checkpoint) Done(rel string) error {
+	if c == nil {
+		return nil
+	}
+	c.state.Last = rel
+	c.dirty = true
+	if time.Since(c.saved) < SaveInterval {
+		return nil
+	}
+	return c.Save()
+}
+
+// Save writes the checkpoint file. It replaces the file atomically, so a
+// crash leaves either the old or the new checkpoint.
+func (c *Checkpoint) Save() error {
+	if c == nil || !c.dirty {
+		return nil
+	}
+	b, err := json.Marshal(c.state)
+	if err != nil {
+		return err
+	}
+
+	tmp := c.name + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.name); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	c.saved = time.Now()
+	c.dirty = false
+	return nil
+}
+
+// Remove deletes the checkpoint file, once the job has completed.
+func (c *Checkpoint) Remove() error {
+	if c == nil {
+		return nil
+	}
+	if err := os.Remove(c.name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// compare orders paths in walk order.
+func compare(a, b string) int {
+	as, bs := split(a), split(b)
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if c := strings.Compare(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+	return len(as) - len(bs)
+}
+
+func split(p string) []string {
+	if p == "" || p == "." {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+// isAncestor reports whether dir is a proper ancestor of p.
+func isAncestor(dir, p string) bool {
+	if dir == "" || dir == "." {
+		return p != dir
+	}
+	return strings.HasPrefix(p, dir+"/")
+}
+
+// Options configure Walk. Both fields may be nil.
+type Options struct {
+	Limiter    *Limiter
+	Checkpoint *Checkpoint
+}
+
+// Walk calls fn for each file in the tree at root, like filepath.Walk,
+// skipping the paths completed according to the checkpoint and waiting
+// on the limiter before each call, charging the size of regular files.
+// After fn returns nil for a path, it is recorded in the checkpoint; Walk
+// saves the checkpoint before returning. fn may return filepath.SkipDir.
+func Walk(root string, opts *Options, fn filepath.WalkFunc) error {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		r, rerr := filepath.Rel(root, path)
+		if rerr != nil {
+			return rerr
+		}
+		rel := filepath.ToSlash(r)
+		if rel == "." {
+			rel = ""
+		}
+
+		if o.Checkpoint.Completed(rel) {
+			switch {
+			case err != nil || !fi.IsDir():
+				return nil
+			case o.Checkpoint.Within(rel):
+				// Continue with the entries.
+				return nil
+			}
+			return filepath.SkipDir
+		}
+
+		var size int64
+		if err == nil && fi.Mode().IsRegular() {
+			size = fi.Size()
+		}
+		o.Limiter.Wait(size)
+
+		if err := fn(path, fi, err); err != nil {
+			if err == filepath.SkipDir {
+				if derr := o.Checkpoint.Done(rel); derr != nil {
+					return derr
+				}
+			}
+			return err
+		}
+		return o.Checkpoint.Done(rel)
+	})
+	if serr := o.Checkpoint.Save(); err == nil {
+		err = serr
+	}
+	return err
+}
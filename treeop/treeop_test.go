@@ -0,0 +1,149 @@
+package treeop
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	var now time.Time
+	var slept []time.Duration
+	l := NewLimiter(10, 1000)
+	l.now = func() time.Time { return now }
+	l.sleep = func(d time.Duration) { slept = append(slept, d); now = now.Add(d) }
+
+	l.Wait(0)   // first op goes right away
+	l.Wait(0)   // 10 ops/s: 100ms later
+	l.Wait(500) // 500ms of bytes, booked from when it asked
+	l.Wait(0)   // waits for the bytes, 400ms after the previous op
+	expected := []time.Duration{100 * time.Millisecond, 100 * time.Millisecond, 400 * time.Millisecond}
+	if !reflect.DeepEqual(slept, expected) {
+		t.Errorf("Wait(): slept %v, expected %v", slept, expected)
+	}
+
+	var unlimited *Limiter
+	unlimited.Wait(1 << 30)
+	NewLimiter(0, 0).Wait(1 << 30)
+}
+
+func TestCompare(t *testing.T) {
+	// Walk order, as produced by filepath.Walk.
+	order := []string{"", "a", "a/b", "a/b/c", "a.txt", "b", "b/a"}
+	for i := range order {
+		for j := range order {
+			c := compare(order[i], order[j])
+			if (c < 0) != (i < j) || (c == 0) != (i == j) {
+				t.Errorf("compare(%q, %q): got %d", order[i], order[j], c)
+			}
+		}
+	}
+}
+
+func mktree(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "test_treeop_")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %v", err)
+	}
+	for _, name := range []string{"a/1", "a/2", "a.txt", "b/c/3", "b/4", "z"} {
+		path := filepath.Join(dir, "tree", filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, []byte(name), 0644)
+	}
+	return dir
+}
+
+func TestWalkResume(t *testing.T) {
+	dir := mktree(t)
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "tree")
+	cpName := filepath.Join(dir, "checkpoint")
+
+	defer func(d time.Duration) { SaveInterval = d }(SaveInterval)
+	SaveInterval = 0
+
+	walk := func(failAt string) ([]string, error) {
+		cp, err := OpenCheckpoint(cpName, "test")
+		if err != nil {
+			t.Fatalf("OpenCheckpoint() failed: %v", err)
+		}
+		var seen []string
+		err = Walk(root, &Options{Checkpoint: cp, Limiter: NewLimiter(0, 1<<30)}, func(path string, fi os.FileInfo, err error) error {
+			rel, _ := filepath.Rel(root, path)
+			rel = filepath.ToSlash(rel)
+			if rel == failAt {
+				return errors.New("crash")
+			}
+			seen = append(seen, rel)
+			return nil
+		})
+		return seen, err
+	}
+
+	first, err := walk("b/c/3")
+	if err == nil {
+		t.Fatal("Walk(): expected error")
+	}
+	if expected := []string{".", "a", "a/1", "a/2", "a.txt", "b", "b/4", "b/c"}; !reflect.DeepEqual(first, expected) {
+		t.Errorf("Walk(): got %v, expected %v", first, expected)
+	}
+
+	second, err := walk("")
+	if err != nil {
+		t.Fatalf("Walk() failed: %v", err)
+	}
+	if expected := []string{"b/c/3", "z"}; !reflect.DeepEqual(second, expected) {
+		t.Errorf("Walk(resumed): got %v, expected %v", second, expected)
+	}
+
+	if _, err := OpenCheckpoint(cpName, "other"); err != ErrJobMismatch {
+		t.Errorf("OpenCheckpoint(): got %v, expected ErrJobMismatch", err)
+	}
+	cp, _ := OpenCheckpoint(cpName, "test")
+	if cp.Last() != "z" || !cp.Completed("b/c") || cp.Within("b") {
+		t.Errorf("checkpoint: got last %q", cp.Last())
+	}
+	if err := cp.Remove(); err != nil {
+		t.Errorf("Remove() failed: %v", err)
+	}
+	if _, err := os.Stat(cpName); !os.IsNotExist(err) {
+		t.Errorf("Remove(): checkpoint still there")
+	}
+}
+
+func TestWalkSkipDirSaveError(t *testing.T) {
+	dir := mktree(t)
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "tree")
+	cpDir := filepath.Join(dir, "state")
+	os.Mkdir(cpDir, 0755)
+
+	defer func(d time.Duration) { SaveInterval = d }(SaveInterval)
+	SaveInterval = 0
+
+	cp, err := OpenCheckpoint(filepath.Join(cpDir, "checkpoint"), "test")
+	if err != nil {
+		t.Fatalf("OpenCheckpoint() failed: %v", err)
+	}
+	var seen []string
+	err = Walk(root, &Options{Checkpoint: cp}, func(path string, fi os.FileInfo, err error) error {
+		rel, _ := filepath.Rel(root, path)
+		seen = append(seen, filepath.ToSlash(rel))
+		if rel == "a" {
+			// The checkpoint can't be saved once its directory is gone.
+			os.RemoveAll(cpDir)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if !os.IsNotExist(err) {
+		t.Errorf("Walk(): got %v, expected the checkpoint save error", err)
+	}
+	if expected := []string{".", "a"}; !reflect.DeepEqual(seen, expected) {
+		t.Errorf("Walk(): visited %q, expected %q", seen, expected)
+	}
+}
@@ -0,0 +1,236 @@
+//go:build go1.18
+// +build go1.18
+
+// Package typed declares extended attributes once, with their name and
+// encoding, and reads and writes them as Go values:
+//
+//	var mimeType = typed.New("user.mime_type", typed.String,
+//		typed.WithDefault("application/octet-stream"))
+//	var hits = typed.New("user.hits", typed.Int64,
+//		typed.WithValidator(func(n int64) error {
+//			if n < 0 {
+//				return errors.New("negative")
+//			}
+//			return nil
+//		}))
+//
+//	t, err := mimeType.Get(path)
+//	err = hits.Set(path, 42)
+package typed
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ivaxer/go-xattr"
+)
+
+// Codec converts values of type T to and from attribute values.
+type Codec[T any] interface {
+	Encode(v T) ([]byte, error)
+	Decode(b []byte) (T, error)
+}
+
+// Attr is a typed handle on an attribute.
+type Attr[T any] struct {
+	name       string
+	codec      Codec[T]
+	def        T
+	hasDefault bool
+	validators []func(T) error
+}
+
+// Option configures an Attr.
+type Option[T any] func(*Attr[T])
+
+// WithDefault makes Get return v instead of an error when the attribute
+// is not set.
+func WithDefault[T any](v T) Option[T] {
+	return func(a *Attr[T]) {
+		a.def, a.hasDefault = v, true
+	}
+}
+
+// WithValidator adds a check run on values passed to Set and returned by
+// Get. Defaults are not validated.
+func WithValidator[T any](f func(T) error) Option[T] {
+	return func(a *Attr[T]) {
+		a.validators = append(a.validators, f)
+	}
+}
+
+// New returns a handle on the attribute name encoded with codec.
+func New[T any](name string, codec Codec[T], opts ...Option[T]) *Attr[T] {
+	a := &Attr[T]{name: name, codec: codec}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// InvalidValueError is returned when a value fails to encode, decode or
+// validate.
+type InvalidValueError struct {
+	Path string
+	Name string
+	Err  error
+}
+
+func (e *InvalidValueError) Error() string {
+	return fmt.Sprintf("typed: invalid %s on %s: %v", e.Name, e.Path, e.Err)
+}
+
+func (e *InvalidValueError) Unwrap() error {
+	return e.Err
+}
+
+// Name returns the attribute name.
+func (a *Attr[T]) Name() string {
+	return a.name
+}
+
+func (a *Attr[T]) validate(path string, v T) error {
+	for _, f := range a.validators {
+		if err := f(v); err != nil {
+			return &InvalidValueError{Path: path, Name: a.name, Err: err}
+		}
+	}
+	return nil
+}
+
+// Get returns the value of the attribute on path. If the attribute is not
+// set, Get returns the default if there is one and otherwise an error
+// satisfying xattr.IsNotExist.
+func (a *Attr[T]) Get(path string) (T, error) {
+	var zero T
+	b, err := xattr.Get(path, a.name)
+	if err != nil {
+		if a.hasDefault && xattr.IsNotExist(err) {
+			return a.def, nil
+		}
+		return zero, err
+	}
+
+	v, err := a.codec.Decode(b)
+	if err != nil {
+		return zero, &InvalidValueError{Path: path, Name: a.name, Err: err}
+	}
+	if err := a.validate(path, v); err != nil {
+		return zero, err
+	}
+	return v, nil
+}
+
+// Set validates v and sets it as the value of the attribute on path.
+func (a *Attr[T]) Set(path string, v T) error {
+	if err := a.validate(path, v); err != nil {
+		return err
+	}
+	b, err := a.codec.Encode(v)
+	if err != nil {
+		return &InvalidValueError{Path: path, Name: a.name, Err: err}
+	}
+	return xattr.Set(path, a.name, b)
+}
+
+// Delete removes the attribute from path. Removing an attribute that is
+// not set is not an error.
+func (a *Attr[T]) Delete(path string) error {
+	if err := xattr.Remove(path, a.name); err != nil && !xattr.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Exists reports whether the attribute is set on path.
+func (a *Attr[T]) Exists(path string) (bool, error) {
+	_, err := xattr.Get(path, a.name)
+	if err != nil {
+		if xattr.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Codecs for common types. Numbers, booleans and times are stored as text
+// so the attributes stay readable with getfattr.
+var (
+	String Codec[string]    = stringCodec{}
+	Bytes  Codec[[]byte]    = bytesCodec{}
+	Int64  Codec[int64]     = int64Codec{}
+	Uint64 Codec[uint64]    = uint64Codec{}
+	Bool   Codec[bool]      = boolCodec{}
+	Time   Codec[time.Time] = timeCodec{}
+)
+
+type stringCodec struct{}
+
+func (stringCodec) Encode(v string) ([]byte, error) { return []byte(v), nil }
+func (stringCodec) Decode(b []byte) (string, error) { return string(b), nil }
+
+type bytesCodec struct{}
+
+func (bytesCodec) Encode(v []byte) ([]byte, error) { return v, nil }
+func (bytesCodec) Decode(b []byte) ([]byte, error) { return b, nil }
+
+type int64Codec struct{}
+
+func (int64Codec) Encode(v int64) ([]byte, error) {
+	return strconv.AppendInt(nil, v, 10), nil
+}
+
+func (int64Codec) Decode(b []byte) (int64, error) {
+	return strconv.ParseInt(string(b), 10, 64)
+}
+
+type uint64Codec struct{}
+
+func (uint64Codec) Encode(v uint64) ([]byte, error) {
+	return strconv.AppendUint(nil, v, 10), nil
+}
+
+func (uint64Codec) Decode(b []byte) (uint64, error) {
+	return strconv.ParseUint(string(b), 10, 64)
+}
+
+type boolCodec struct{}
+
+func (boolCodec) Encode(v bool) ([]byte, error) {
+	return strconv.AppendBool(nil, v), nil
+}
+
+func (boolCodec) Decode(b []byte) (bool, error) {
+	return strconv.ParseBool(string(b))
+}
+
+// timeCodec stores times in RFC 3339 format with nanoseconds.
+type timeCodec struct{}
+
+func (timeCodec) Encode(v time.Time) ([]byte, error) {
+	return []byte(v.Format(time.RFC3339Nano)), nil
+}
+
+func (timeCodec) Decode(b []byte) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, string(b))
+}
+
+// JSON returns a codec storing values as JSON.
+func JSON[T any]() Codec[T] {
+	return jsonCodec[T]{}
+}
+
+type jsonCodec[T any] struct{}
+
+func (jsonCodec[T]) Encode(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec[T]) Decode(b []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(b, &v)
+	return v, err
+}
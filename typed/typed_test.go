@@ -0,0 +1,103 @@
+//go:build go1.18
+// +build go1.18
+
+package typed
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ivaxer/go-xattr"
+	"github.com/ivaxer/go-xattr/xattrtest"
+)
+
+type owner struct {
+	Team  string   `json:"team"`
+	Email []string `json:"email"`
+}
+
+func TestAttr(t *testing.T) {
+	path := xattrtest.TempFile(t)
+
+	errNegative := errors.New("negative")
+	hits := New("user.hits", Int64, WithDefault[int64](-1), WithValidator(func(n int64) error {
+		if n < 0 {
+			return errNegative
+		}
+		return nil
+	}))
+
+	if n, err := hits.Get(path); err != nil || n != -1 {
+		t.Errorf("Get(): got %d, %v, expected the default", n, err)
+	}
+	if ok, err := hits.Exists(path); err != nil || ok {
+		t.Errorf("Exists(): got %v, %v, expected false", ok, err)
+	}
+
+	if err := hits.Set(path, 42); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if b, _ := xattr.Get(path, "user.hits"); string(b) != "42" {
+		t.Errorf("Set(): stored %q", b)
+	}
+	if n, err := hits.Get(path); err != nil || n != 42 {
+		t.Errorf("Get(): got %d, %v, expected 42", n, err)
+	}
+	if ok, err := hits.Exists(path); err != nil || !ok {
+		t.Errorf("Exists(): got %v, %v, expected true", ok, err)
+	}
+
+	var invalid *InvalidValueError
+	if err := hits.Set(path, -5); !errors.As(err, &invalid) || !errors.Is(err, errNegative) {
+		t.Errorf("Set(-5): got %v, expected validation error", err)
+	}
+	xattr.Set(path, "user.hits", []byte("-7"))
+	if _, err := hits.Get(path); !errors.Is(err, errNegative) {
+		t.Errorf("Get(): got %v, expected validation error", err)
+	}
+	xattr.Set(path, "user.hits", []byte("many"))
+	if _, err := hits.Get(path); !errors.As(err, &invalid) {
+		t.Errorf("Get(): got %v, expected decode error", err)
+	}
+
+	if err := hits.Delete(path); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if err := hits.Delete(path); err != nil {
+		t.Errorf("Delete(): got %v on missing attribute", err)
+	}
+
+	noDefault := New("user.hits", Int64)
+	if _, err := noDefault.Get(path); !xattr.IsNotExist(err) {
+		t.Errorf("Get(): got %v, expected not exist", err)
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	path := xattrtest.TempFile(t)
+
+	when := time.Date(2023, 5, 17, 8, 30, 0, 123, time.UTC)
+	stamp := New("user.stamp", Time)
+	if err := stamp.Set(path, when); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if got, err := stamp.Get(path); err != nil || !got.Equal(when) {
+		t.Errorf("Get(): got %v, %v, expected %v", got, err, when)
+	}
+
+	flag := New("user.flag", Bool)
+	flag.Set(path, true)
+	if got, err := flag.Get(path); err != nil || !got {
+		t.Errorf("Get(): got %v, %v, expected true", got, err)
+	}
+
+	o := owner{Team: "storage", Email: []string{"a@example.com"}}
+	own := New("user.owner", JSON[owner]())
+	if err := own.Set(path, o); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if got, err := own.Get(path); err != nil || got.Team != o.Team || len(got.Email) != 1 {
+		t.Errorf("Get(): got %+v, %v, expected %+v", got, err, o)
+	}
+}
@@ -0,0 +1,18 @@
+package xattrhttp
+
+import (
+	"os"
+	"syscall"
+)
+
+type fileKey struct {
+	dev, ino uint64
+}
+
+func inode(fi os.FileInfo) (fileKey, int64, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileKey{}, 0, false
+	}
+	return fileKey{uint64(st.Dev), uint64(st.Ino)}, st.Ctimespec.Nano(), true
+}
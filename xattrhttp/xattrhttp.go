@@ -0,0 +1,161 @@
+// Package xattrhttp provides an HTTP file server that takes response
+// headers from extended attributes of the served files.
+package xattrhttp
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ivaxer/go-xattr"
+)
+
+// Config names the attributes headers are taken from. An empty name
+// disables the header.
+type Config struct {
+	ContentType     string
+	ETag            string
+	CacheControl    string
+	ContentLanguage string
+}
+
+// DefaultConfig uses the freedesktop.org user.mime_type attribute for
+// Content-Type and a SHA-256 digest in user.sha256 for ETag.
+var DefaultConfig = Config{
+	ContentType:     "user.mime_type",
+	ETag:            "user.sha256",
+	CacheControl:    "user.cache_control",
+	ContentLanguage: "user.content_language",
+}
+
+// maxCacheEntries bounds the metadata cache. The cache is dropped as a
+// whole when it fills up.
+const maxCacheEntries = 4096
+
+// headers holds the header values found on a file.
+type headers struct {
+	ctime                                        int64
+	contentType, etag, cacheControl, contentLang string
+}
+
+type handler struct {
+	root string
+	cfg  Config
+	fs   http.Handler
+
+	mu    sync.Mutex
+	cache map[fileKey]*headers
+}
+
+// FileServer returns a handler that serves files from the directory root
+// like http.FileServer and sets Content-Type, ETag, Cache-Control and
+// Content-Language from the attributes named in cfg. If cfg is nil,
+// DefaultConfig is used.
+//
+// Headers are cached per inode and refreshed when the inode change time
+// moves, which setting an attribute does.
+func FileServer(root string, cfg *Config) http.Handler {
+	if cfg == nil {
+		cfg = &DefaultConfig
+	}
+	return &handler{
+		root:  root,
+		cfg:   *cfg,
+		fs:    http.FileServer(http.Dir(root)),
+		cache: make(map[fileKey]*headers),
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upath := r.URL.Path
+	if !strings.HasPrefix(upath, "/") {
+		upath = "/" + upath
+	}
+	name := filepath.Join(h.root, filepath.FromSlash(path.Clean(upath)))
+
+	if hdr := h.lookup(name); hdr != nil {
+		// http.FileServer leaves headers that are already set alone and
+		// evaluates conditional requests against the ETag set here.
+		set := func(key, value string) {
+			if value != "" {
+				w.Header().Set(key, value)
+			}
+		}
+		set("Content-Type", hdr.contentType)
+		set("Etag", hdr.etag)
+		set("Cache-Control", hdr.cacheControl)
+		set("Content-Language", hdr.contentLang)
+	}
+	h.fs.ServeHTTP(w, r)
+}
+
+// lookup returns the headers for the file name, or nil if it can't be
+// served.
+func (h *handler) lookup(name string) *headers {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil
+	}
+	if fi.IsDir() {
+		name = filepath.Join(name, "index.html")
+		if fi, err = os.Stat(name); err != nil || fi.IsDir() {
+			return nil
+		}
+	}
+
+	key, ctime, ok := inode(fi)
+	if ok {
+		h.mu.Lock()
+		hdr := h.cache[key]
+		h.mu.Unlock()
+		if hdr != nil && hdr.ctime == ctime {
+			return hdr
+		}
+	}
+
+	hdr := &headers{
+		ctime:        ctime,
+		contentType:  h.get(name, h.cfg.ContentType),
+		etag:         quoteETag(h.get(name, h.cfg.ETag)),
+		cacheControl: h.get(name, h.cfg.CacheControl),
+		contentLang:  h.get(name, h.cfg.ContentLanguage),
+	}
+	if ok {
+		h.mu.Lock()
+		if len(h.cache) >= maxCacheEntries {
+			h.cache = make(map[fileKey]*headers)
+		}
+		h.cache[key] = hdr
+		h.mu.Unlock()
+	}
+	return hdr
+}
+
+// get returns the value of attr as a header value. Missing or unreadable
+// attributes and values that aren't valid header values are ignored.
+func (h *handler) get(name, attr string) string {
+	if attr == "" {
+		return ""
+	}
+	b, err := xattr.Get(name, attr)
+	if err != nil {
+		return ""
+	}
+	s := strings.TrimSpace(string(b))
+	if strings.ContainsAny(s, "\r\n\x00") {
+		return ""
+	}
+	return s
+}
+
+// quoteETag turns a digest into an entity tag. Values that already are
+// entity tags are used as is.
+func quoteETag(s string) string {
+	if s == "" || strings.HasPrefix(s, `"`) || strings.HasPrefix(s, `W/"`) {
+		return s
+	}
+	return `"` + s + `"`
+}
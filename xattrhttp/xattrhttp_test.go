@@ -0,0 +1,93 @@
+package xattrhttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/ivaxer/go-xattr"
+	"github.com/ivaxer/go-xattr/xattrtest"
+)
+
+func mkfile(t *testing.T, path string, attrs map[string]string) {
+	if err := ioutil.WriteFile(path, []byte("body"), 0644); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+	for attr, value := range attrs {
+		if err := xattr.Set(path, attr, []byte(value)); err != nil {
+			t.Fatalf("Set(%q, %q) failed: %v", path, attr, err)
+		}
+	}
+}
+
+func get(t *testing.T, h http.Handler, url string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", url, nil)
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func checkHeader(t *testing.T, w *httptest.ResponseRecorder, key, expected string) {
+	if got := w.Header().Get(key); got != expected {
+		t.Errorf("%s: got %q, expected %q", key, got, expected)
+	}
+}
+
+func TestFileServer(t *testing.T) {
+	root := xattrtest.TempDir(t)
+
+	mkfile(t, filepath.Join(root, "data.bin"), map[string]string{
+		"user.mime_type":        "application/x-custom",
+		"user.sha256":           "abc123",
+		"user.cache_control":    "max-age=60",
+		"user.content_language": "de",
+	})
+	mkfile(t, filepath.Join(root, "plain.txt"), nil)
+
+	h := FileServer(root, nil)
+
+	w := get(t, h, "/data.bin", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /data.bin: status %d", w.Code)
+	}
+	checkHeader(t, w, "Content-Type", "application/x-custom")
+	checkHeader(t, w, "Etag", `"abc123"`)
+	checkHeader(t, w, "Cache-Control", "max-age=60")
+	checkHeader(t, w, "Content-Language", "de")
+
+	w = get(t, h, "/data.bin", map[string]string{"If-None-Match": `"abc123"`})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("conditional GET: status %d, expected %d", w.Code, http.StatusNotModified)
+	}
+
+	w = get(t, h, "/plain.txt", nil)
+	checkHeader(t, w, "Content-Type", "text/plain; charset=utf-8")
+	checkHeader(t, w, "Etag", "")
+
+	// Changing an attribute changes the inode ctime and invalidates the
+	// cached headers.
+	if err := xattr.Set(filepath.Join(root, "data.bin"), "user.mime_type", []byte("image/png")); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	w = get(t, h, "/data.bin", nil)
+	checkHeader(t, w, "Content-Type", "image/png")
+}
+
+func TestFileServerConfig(t *testing.T) {
+	root := xattrtest.TempDir(t)
+
+	mkfile(t, filepath.Join(root, "index.html"), map[string]string{
+		"user.type": "text/x-test",
+		"user.etag": `W/"v1"`,
+	})
+
+	h := FileServer(root, &Config{ContentType: "user.type", ETag: "user.etag"})
+	w := get(t, h, "/", nil)
+	checkHeader(t, w, "Content-Type", "text/x-test")
+	checkHeader(t, w, "Etag", `W/"v1"`)
+}
@@ -0,0 +1,301 @@
+// Package xattrtest provides helpers for testing code that uses extended
+// attributes.
+//
+// TempFile and TempDir create files in a directory whose filesystem
+// supports user extended attributes, trying $TEST_XATTR_PATH, the system
+// temporary directory, /var/tmp and the working directory in turn, and
+// skip the test if there is none. tmpfs, for example, only supports user
+// attributes on recent kernels.
+//
+// The Check functions mirror the helpers of this package's own tests and
+// work on any Backend: OS, which uses the xattr package, or a Memory
+// backend, whose errors match those of OS, for checking the helpers
+// themselves or code written against Backend without a real filesystem.
+package xattrtest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/ivaxer/go-xattr"
+	"github.com/ivaxer/go-xattr/internal/errno"
+)
+
+// Reader is the read side of a Backend. ext4.Image and squashfs.Image
+// implement it.
+type Reader interface {
+	List(path string) ([]string, error)
+	Get(path, name string) ([]byte, error)
+}
+
+// Backend stores extended attributes.
+type Backend interface {
+	Reader
+	Set(path, name string, value []byte) error
+	Remove(path, name string) error
+}
+
+type osBackend struct{}
+
+func (osBackend) List(path string) ([]string, error)        { return xattr.List(path) }
+func (osBackend) Get(path, name string) ([]byte, error)     { return xattr.Get(path, name) }
+func (osBackend) Set(path, name string, value []byte) error { return xattr.Set(path, name, value) }
+func (osBackend) Remove(path, name string) error            { return xattr.Remove(path, name) }
+
+// OS is the Backend of the xattr package.
+var OS Backend = osBackend{}
+
+// Memory is an in-memory Backend. Its errors match those of the system
+// calls, so os.IsNotExist and xattr.IsNotExist work on them. Files must be
+// created with Create before attributes can be set on them.
+type Memory struct {
+	mu    sync.Mutex
+	files map[string]map[string][]byte
+}
+
+// NewMemory returns an empty Memory backend.
+func NewMemory() *Memory {
+	return &Memory{files: make(map[string]map[string][]byte)}
+}
+
+// Create adds the file path, without attributes. Creating an existing file
+// is a no-op.
+func (m *Memory) Create(path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	path = filepath.Clean(path)
+	if m.files[path] == nil {
+		m.files[path] = make(map[string][]byte)
+	}
+}
+
+func (m *Memory) file(op, path string) (map[string][]byte, error) {
+	attrs, ok := m.files[filepath.Clean(path)]
+	if !ok {
+		return nil, &os.PathError{Op: op, Path: path, Err: syscall.ENOENT}
+	}
+	return attrs, nil
+}
+
+// List returns the attribute names of path, sorted.
+func (m *Memory) List(path string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	attrs, err := m.file("listxattr", path)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Get returns a copy of the value of name on path.
+func (m *Memory) Get(path, name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	attrs, err := m.file("getxattr", path)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := attrs[name]
+	if !ok {
+		return nil, &os.PathError{Op: "getxattr", Path: path, Err: errno.NoAttr}
+	}
+	return append([]byte{}, v...), nil
+}
+
+// Set stores a copy of value as name on path.
+func (m *Memory) Set(path, name string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	attrs, err := m.file("setxattr", path)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return &os.PathError{Op: "setxattr", Path: path, Err: syscall.EINVAL}
+	}
+	attrs[name] = append([]byte{}, value...)
+	return nil
+}
+
+// Remove removes name from path.
+func (m *Memory) Remove(path, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	attrs, err := m.file("removexattr", path)
+	if err != nil {
+		return err
+	}
+	if _, ok := attrs[name]; !ok {
+		return &os.PathError{Op: "removexattr", Path: path, Err: errno.NoAttr}
+	}
+	delete(attrs, name)
+	return nil
+}
+
+// probeAttr is set and removed to check for attribute support.
+const probeAttr = "user.xattrtest.probe"
+
+var (
+	probeOnce sync.Once
+	probeDir  string
+)
+
+// findDir returns the first candidate directory supporting user
+// attributes, or "".
+func findDir() string {
+	probeOnce.Do(func() {
+		candidates := []string{os.Getenv("TEST_XATTR_PATH"), os.TempDir(), "/var/tmp", "."}
+		for _, dir := range candidates {
+			if dir != "" && supported(dir) {
+				probeDir = dir
+				return
+			}
+		}
+	})
+	return probeDir
+}
+
+func supported(dir string) bool {
+	f, err := ioutil.TempFile(dir, "xattrtest_probe_")
+	if err != nil {
+		return false
+	}
+	defer func() { f.Close(); os.Remove(f.Name()) }()
+	return xattr.Set(f.Name(), probeAttr, []byte("1")) == nil
+}
+
+// TempDir returns a new directory supporting user attributes, removed
+// when the test ends, or skips the test if there is no such place.
+func TempDir(t testing.TB) string {
+	t.Helper()
+	base := findDir()
+	if base == "" {
+		t.Skip("no directory supporting user extended attributes found; set TEST_XATTR_PATH")
+	}
+	dir, err := ioutil.TempDir(base, "xattrtest_")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// TempFile returns the name of a new empty file supporting user attributes,
+// removed when the test ends, or skips the test if there is no such place.
+func TempFile(t testing.TB) string {
+	t.Helper()
+	f, err := ioutil.TempFile(TempDir(t), "test_xattr_")
+	if err != nil {
+		t.Fatalf("TempFile() failed: %v", err)
+	}
+	f.Close()
+	return f.Name()
+}
+
+// CheckList checks that path has exactly the attributes expected, in any
+// order.
+func CheckList(t testing.TB, b Backend, path string, expected ...string) {
+	t.Helper()
+	got, err := b.List(path)
+	if err != nil {
+		t.Fatalf("List(%q) failed: %v", path, err)
+	}
+
+	got = append([]string{}, got...)
+	want := append([]string{}, expected...)
+	sort.Strings(got)
+	sort.Strings(want)
+	if len(got) != len(want) {
+		t.Errorf("List(%q): got %v, expected %v", path, got, want)
+		return
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("List(%q): got %v, expected %v", path, got, want)
+			return
+		}
+	}
+}
+
+// CheckListError checks that listing path fails with an error satisfying f.
+func CheckListError(t testing.TB, b Backend, path string, f func(error) bool) {
+	t.Helper()
+	got, err := b.List(path)
+	if !f(err) {
+		t.Errorf("List(%q): unexpected error value: %v", path, err)
+	}
+	if got != nil {
+		t.Error("List(): expected nil slice on error")
+	}
+}
+
+// CheckSet sets attr on path and fails the test on error.
+func CheckSet(t testing.TB, b Backend, path, attr string, data []byte) {
+	t.Helper()
+	if err := b.Set(path, attr, data); err != nil {
+		t.Fatalf("Set(%q, %q, %v) failed: %v", path, attr, data, err)
+	}
+}
+
+// CheckSetError checks that setting attr on path fails with an error
+// satisfying f.
+func CheckSetError(t testing.TB, b Backend, path, attr string, data []byte, f func(error) bool) {
+	t.Helper()
+	if err := b.Set(path, attr, data); !f(err) {
+		t.Errorf("Set(%q, %q, %v): unexpected error value: %v", path, attr, data, err)
+	}
+}
+
+// CheckGet checks that attr on path holds expected.
+func CheckGet(t testing.TB, b Backend, path, attr string, expected []byte) {
+	t.Helper()
+	got, err := b.Get(path, attr)
+	if err != nil {
+		t.Fatalf("Get(%q, %q) failed: %v", path, attr, err)
+	}
+	if !bytes.Equal(got, expected) {
+		t.Errorf("Get(%q, %q): got %v, expected %v", path, attr, got, expected)
+	}
+}
+
+// CheckGetError checks that getting attr on path fails with an error
+// satisfying f, such as xattr.IsNotExist.
+func CheckGetError(t testing.TB, b Backend, path, attr string, f func(error) bool) {
+	t.Helper()
+	got, err := b.Get(path, attr)
+	if !f(err) {
+		t.Errorf("Get(%q, %q): unexpected error value: %v", path, attr, err)
+	}
+	if got != nil {
+		t.Error("Get(): expected nil slice on error")
+	}
+}
+
+// CheckRemove removes attr from path and fails the test on error.
+func CheckRemove(t testing.TB, b Backend, path, attr string) {
+	t.Helper()
+	if err := b.Remove(path, attr); err != nil {
+		t.Fatalf("Remove(%q, %q) failed: %v", path, attr, err)
+	}
+}
+
+// CheckRemoveError checks that removing attr from path fails with an error
+// satisfying f.
+func CheckRemoveError(t testing.TB, b Backend, path, attr string, f func(error) bool) {
+	t.Helper()
+	if err := b.Remove(path, attr); !f(err) {
+		t.Errorf("Remove(%q, %q): unexpected error value: %v", path, attr, err)
+	}
+}
@@ -0,0 +1,77 @@
+package xattrtest
+
+import (
+	"os"
+	"testing"
+
+	"github.com/ivaxer/go-xattr"
+	"github.com/ivaxer/go-xattr/ext4"
+	"github.com/ivaxer/go-xattr/squashfs"
+)
+
+var (
+	_ Backend = NewMemory()
+	_ Reader  = (*ext4.Image)(nil)
+	_ Reader  = (*squashfs.Image)(nil)
+)
+
+// flow runs the scenario of the xattr package's TestFlow on b.
+func flow(t *testing.T, b Backend, path string) {
+	data := []byte("test xattr data")
+	attr := "user.test xattr"
+	attr2 := "user.text xattr 2"
+
+	CheckList(t, b, path)
+	CheckSet(t, b, path, attr, data)
+	CheckList(t, b, path, attr)
+	CheckSet(t, b, path, attr2, data)
+	CheckList(t, b, path, attr2, attr)
+	CheckGet(t, b, path, attr, data)
+	CheckGetError(t, b, path, "user.unknown attr", xattr.IsNotExist)
+	CheckRemoveError(t, b, path, "user.unknown attr", xattr.IsNotExist)
+	CheckRemove(t, b, path, attr)
+	CheckList(t, b, path, attr2)
+	CheckSet(t, b, path, attr2, []byte{})
+	CheckGet(t, b, path, attr2, []byte{})
+	CheckRemove(t, b, path, attr2)
+	CheckList(t, b, path)
+
+	missing := path + ".missing"
+	CheckListError(t, b, missing, os.IsNotExist)
+	CheckSetError(t, b, missing, attr, data, os.IsNotExist)
+	CheckGetError(t, b, missing, attr, os.IsNotExist)
+	CheckRemoveError(t, b, missing, attr, os.IsNotExist)
+}
+
+func TestOS(t *testing.T) {
+	flow(t, OS, TempFile(t))
+}
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	m.Create("/a/file")
+	flow(t, m, "/a/file")
+
+	m.Set("/a/./file", "user.x", []byte("1"))
+	value := []byte("2")
+	m.Set("/a/file", "user.y", value)
+	value[0] = '3'
+	CheckGet(t, m, "/a/file", "user.y", []byte("2"))
+	CheckList(t, m, "/a/file", "user.x", "user.y")
+}
+
+func TestTempDir(t *testing.T) {
+	var dir string
+	t.Run("create", func(t *testing.T) {
+		dir = TempDir(t)
+		if !supported(dir) {
+			t.Errorf("TempDir(): %s doesn't support user attributes", dir)
+		}
+	})
+	if dir == "" {
+		return
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("TempDir(): %s not removed", dir)
+	}
+}
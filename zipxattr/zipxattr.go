@@ -0,0 +1,89 @@
+// Package zipxattr carries extended attributes through zip archives the
+// way ditto and Archive Utility on macOS do.
+//
+// The attributes of member "dir/name" are stored in an AppleDouble member
+// "__MACOSX/dir/._name", see package appledouble. Archives created with
+// ditto -c -k --sequesterRsrc, or by zipping a tree that still contains
+// "._" files, are read as well.
+package zipxattr
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ivaxer/go-xattr/appledouble"
+)
+
+// SidecarDir is the directory holding sidecars in zip archives.
+const SidecarDir = "__MACOSX/"
+
+// maxSidecarSize bounds the sidecars read into memory.
+const maxSidecarSize = 64 << 20
+
+// SidecarName returns the name of the member carrying the attributes of
+// the member name.
+func SidecarName(name string) string {
+	return SidecarDir + appledouble.SidecarName(name)
+}
+
+// IsSidecar reports whether the member name carries attributes or is
+// otherwise part of the sidecar tree, and should be skipped on extraction.
+func IsSidecar(name string) bool {
+	if strings.HasPrefix(name, SidecarDir) {
+		return true
+	}
+	_, ok := appledouble.TargetName(name)
+	return ok
+}
+
+// WriteXattrs adds a sidecar carrying attrs for the member name to zw. It
+// may be called before or after the member itself is added; ditto writes
+// sidecars after their members.
+func WriteXattrs(zw *zip.Writer, name string, attrs map[string][]byte) error {
+	if len(attrs) == 0 {
+		return nil
+	}
+	b, err := appledouble.Encode(attrs)
+	if err != nil {
+		return err
+	}
+	w, err := zw.Create(SidecarName(name))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// ReadXattrs returns the attributes carried by the sidecars in zr, keyed
+// by the name of the member they belong to. Directory members are keyed
+// without the trailing slash. Sidecars that aren't valid AppleDouble files
+// are ignored; when both forms are present for a member, the one under
+// "__MACOSX/" wins.
+func ReadXattrs(zr *zip.Reader) (map[string]map[string][]byte, error) {
+	xattrs := make(map[string]map[string][]byte)
+	for _, f := range zr.File {
+		target, ok := appledouble.TargetName(f.Name)
+		if !ok || f.FileInfo().IsDir() || f.UncompressedSize64 > maxSidecarSize {
+			continue
+		}
+		if _, seen := xattrs[target]; seen && !strings.HasPrefix(f.Name, SidecarDir) {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		b, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		if attrs, err := appledouble.Decode(b); err == nil {
+			xattrs[target] = attrs
+		}
+	}
+	return xattrs, nil
+}
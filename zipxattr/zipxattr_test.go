@@ -0,0 +1,79 @@
+package zipxattr
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/ivaxer/go-xattr/appledouble"
+)
+
+func TestRoundTrip(t *testing.T) {
+	attrs := map[string][]byte{
+		"com.apple.quarantine": []byte("0083;5f3c1b7b;Safari;"),
+		"user.sha256":          []byte("e3b0c44298fc1c149afbf4c8996fb924"),
+	}
+	dotAttrs := map[string][]byte{"user.origin": []byte("dot file")}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"docs/", "docs/readme.txt", "other.txt"} {
+		if _, err := zw.Create(name); err != nil {
+			t.Fatalf("Create(%q) failed: %v", name, err)
+		}
+	}
+	if err := WriteXattrs(zw, "docs/readme.txt", attrs); err != nil {
+		t.Fatalf("WriteXattrs() failed: %v", err)
+	}
+	if err := WriteXattrs(zw, "docs/", map[string][]byte{"user.dir": []byte("1")}); err != nil {
+		t.Fatalf("WriteXattrs() failed: %v", err)
+	}
+	// A "._" file zipped in place, as produced by zip(1) on macOS
+	// volumes that store attributes that way.
+	w, _ := zw.Create("._other.txt")
+	b, _ := appledouble.Encode(dotAttrs)
+	w.Write(b)
+	// A "._" file that isn't AppleDouble.
+	w, _ = zw.Create("._bogus")
+	w.Write([]byte("not appledouble"))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("NewReader() failed: %v", err)
+	}
+	if zr.File[3].Name != "__MACOSX/docs/._readme.txt" {
+		t.Errorf("sidecar name: got %q", zr.File[3].Name)
+	}
+
+	got, err := ReadXattrs(zr)
+	if err != nil {
+		t.Fatalf("ReadXattrs() failed: %v", err)
+	}
+	expected := map[string]map[string][]byte{
+		"docs/readme.txt": attrs,
+		"docs":            {"user.dir": []byte("1")},
+		"other.txt":       dotAttrs,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("ReadXattrs(): got %q, expected %q", got, expected)
+	}
+}
+
+func TestIsSidecar(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"__MACOSX/":             true,
+		"__MACOSX/dir/._f":      true,
+		"dir/._f":               true,
+		"dir/f":                 false,
+		"dir/.hidden":           false,
+		"__MACOSX_not/file.txt": false,
+	} {
+		if got := IsSidecar(name); got != expected {
+			t.Errorf("IsSidecar(%q): got %v, expected %v", name, got, expected)
+		}
+	}
+}